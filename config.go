@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// settings is the schema of the config file: one field per entry of envFlags,
+// keyed by the flag name. A nil field was not given. Values are typed, so
+// "push-retries: three" is rejected when the file is read instead of being
+// ignored later.
+type settings struct {
+	PlanPath                  *string    `yaml:"plan-path" json:"plan-path,omitempty"`
+	PlanLog                   *string    `yaml:"plan-log" json:"plan-log,omitempty"`
+	ApplyLog                  *string    `yaml:"apply-log" json:"apply-log,omitempty"`
+	RefreshLog                *string    `yaml:"refresh-log" json:"refresh-log,omitempty"`
+	LogDir                    *string    `yaml:"log-dir" json:"log-dir,omitempty"`
+	StartTime                 *int64     `yaml:"start-time" json:"start-time,omitempty"`
+	Job                       *string    `yaml:"job" json:"job,omitempty"`
+	PushgatewayURL            stringList `yaml:"pushgateway-url" json:"pushgateway-url,omitempty"`
+	PushgatewayAddress        *string    `yaml:"pushgateway-address" json:"pushgateway-address,omitempty"`
+	PushgatewayScheme         *string    `yaml:"pushgateway-scheme" json:"pushgateway-scheme,omitempty"`
+	PushgatewayPort           *int       `yaml:"pushgateway-port" json:"pushgateway-port,omitempty"`
+	PushgatewayUsername       *string    `yaml:"pushgateway-username" json:"pushgateway-username,omitempty"`
+	PushRequireAll            *bool      `yaml:"push-require-all" json:"push-require-all,omitempty"`
+	PushTimeout               *int       `yaml:"push-timeout" json:"push-timeout,omitempty"`
+	PushRetries               *int       `yaml:"push-retries" json:"push-retries,omitempty"`
+	PushMode                  *string    `yaml:"push-mode" json:"push-mode,omitempty"`
+	Instance                  *string    `yaml:"instance" json:"instance,omitempty"`
+	InstanceLabel             *string    `yaml:"instance-label" json:"instance-label,omitempty"`
+	Workflow                  *string    `yaml:"workflow" json:"workflow,omitempty"`
+	CommitMessage             *string    `yaml:"commit-message" json:"commit-message,omitempty"`
+	ExtraGroupingLabels       *string    `yaml:"extra-grouping-labels" json:"extra-grouping-labels,omitempty"`
+	Output                    stringList `yaml:"output" json:"output,omitempty"`
+	Backend                   *string    `yaml:"backend" json:"backend,omitempty"`
+	DurationHistogram         *bool      `yaml:"duration-histogram" json:"duration-histogram,omitempty"`
+	DurationBuckets           stringList `yaml:"duration-buckets" json:"duration-buckets,omitempty"`
+	PushStateFile             *string    `yaml:"push-state-file" json:"push-state-file,omitempty"`
+	ForcePush                 *bool      `yaml:"force-push" json:"force-push,omitempty"`
+	OutputRequireAll          *bool      `yaml:"output-require-all" json:"output-require-all,omitempty"`
+	OutputJSONPath            *string    `yaml:"output-json-path" json:"output-json-path,omitempty"`
+	TextfilePath              *string    `yaml:"textfile-path" json:"textfile-path,omitempty"`
+	RemoteWriteURL            *string    `yaml:"remote-write-url" json:"remote-write-url,omitempty"`
+	RemoteWriteTenant         *string    `yaml:"remote-write-tenant" json:"remote-write-tenant,omitempty"`
+	RemoteWriteUsername       *string    `yaml:"remote-write-username" json:"remote-write-username,omitempty"`
+	MaxLabelValueLength       *int       `yaml:"max-label-value-length" json:"max-label-value-length,omitempty"`
+	MetricPrefix              *string    `yaml:"metric-prefix" json:"metric-prefix,omitempty"`
+	MetricClamp               *string    `yaml:"metric-clamp" json:"metric-clamp,omitempty"`
+	DuplicateMetricPolicy     *string    `yaml:"duplicate-metric-policy" json:"duplicate-metric-policy,omitempty"`
+	DriftReportPath           *string    `yaml:"drift-report-path" json:"drift-report-path,omitempty"`
+	SecurityScanPath          *string    `yaml:"security-scan-path" json:"security-scan-path,omitempty"`
+	StateJSONPath             *string    `yaml:"state-json-path" json:"state-json-path,omitempty"`
+	LockFilePath              *string    `yaml:"lock-file-path" json:"lock-file-path,omitempty"`
+	TerraformVersion          *string    `yaml:"terraform-version" json:"terraform-version,omitempty"`
+	ProviderVersionsStateFile *string    `yaml:"provider-versions-state-file" json:"provider-versions-state-file,omitempty"`
+	InfracostJSONPath         *string    `yaml:"infracost-json-path" json:"infracost-json-path,omitempty"`
+	ExtraMetricsFile          *string    `yaml:"extra-metrics-file" json:"extra-metrics-file,omitempty"`
+	MetricsInclude            stringList `yaml:"metrics-include" json:"metrics-include,omitempty"`
+	ExporterTimeoutSeconds    *int       `yaml:"exporter-timeout-seconds" json:"exporter-timeout-seconds,omitempty"`
+	GitBranch                 *string    `yaml:"git-branch" json:"git-branch,omitempty"`
+	PrNumber                  *string    `yaml:"pr-number" json:"pr-number,omitempty"`
+	MetricsExclude            stringList `yaml:"metrics-exclude" json:"metrics-exclude,omitempty"`
+	PlanSchemaCheck           *bool      `yaml:"plan-schema-check" json:"plan-schema-check,omitempty"`
+	CountReplaceAsAddDestroy  *bool      `yaml:"count-replace-as-add-destroy" json:"count-replace-as-add-destroy,omitempty"`
+	MaxActionReasons          *int       `yaml:"max-action-reasons" json:"max-action-reasons,omitempty"`
+	DowntimeResourceTypes     stringList `yaml:"downtime-resource-types" json:"downtime-resource-types,omitempty"`
+	DowntimeReportPath        *string    `yaml:"downtime-report-path" json:"downtime-report-path,omitempty"`
+	ThrottlePatterns          stringList `yaml:"throttle-patterns" json:"throttle-patterns,omitempty"`
+	SlowOperationPatterns     stringList `yaml:"slow-operation-patterns" json:"slow-operation-patterns,omitempty"`
+	ConditionFailurePatterns  stringList `yaml:"condition-failure-patterns" json:"condition-failure-patterns,omitempty"`
+	FailOnTerraformError      *bool      `yaml:"fail-on-terraform-error" json:"fail-on-terraform-error,omitempty"`
+	SummaryEnabled            *bool      `yaml:"summary-enabled" json:"summary-enabled,omitempty"`
+	SummaryRequired           *bool      `yaml:"summary-required" json:"summary-required,omitempty"`
+	RunSummary                *bool      `yaml:"run-summary" json:"run-summary,omitempty"`
+	SummaryProvider           *string    `yaml:"summary-provider" json:"summary-provider,omitempty"`
+	SummaryTemplateFile       *string    `yaml:"summary-template-file" json:"summary-template-file,omitempty"`
+	SummaryLanguage           *string    `yaml:"summary-language" json:"summary-language,omitempty"`
+	GeminiModel               *string    `yaml:"gemini-model" json:"gemini-model,omitempty"`
+	PromptFile                *string    `yaml:"prompt-file" json:"prompt-file,omitempty"`
+	SummaryTimeout            *int       `yaml:"summary-timeout" json:"summary-timeout,omitempty"`
+	GeminiMaxRetries          *int       `yaml:"gemini-max-retries" json:"gemini-max-retries,omitempty"`
+	SummaryWordLimit          *int       `yaml:"summary-word-limit" json:"summary-word-limit,omitempty"`
+	RedactPatternsFile        *string    `yaml:"redact-patterns-file" json:"redact-patterns-file,omitempty"`
+	LlmBaseURL                *string    `yaml:"llm-base-url" json:"llm-base-url,omitempty"`
+	LlmModel                  *string    `yaml:"llm-model" json:"llm-model,omitempty"`
+	StepSummary               *string    `yaml:"step-summary" json:"step-summary,omitempty"`
+	SlackNotifyOn             *string    `yaml:"slack-notify-on" json:"slack-notify-on,omitempty"`
+	LogMaxLineBytes           *int       `yaml:"log-max-line-bytes" json:"log-max-line-bytes,omitempty"`
+	LogLevel                  *string    `yaml:"log-level" json:"log-level,omitempty"`
+	LogFormat                 *string    `yaml:"log-format" json:"log-format,omitempty"`
+}
+
+// stringList is a list setting. It may be given as a YAML sequence or as the
+// comma-separated string its environment variable takes.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = stringList{node.Value}
+		return nil
+	}
+	var items []string
+	if err := node.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// defaultSettings are the values a config file starts from. They match the
+// exporter's built-in defaults, so a file that leaves a setting out behaves like
+// an unset environment variable.
+func defaultSettings() settings {
+	return settings{
+		PushMode:               ptr("push"),
+		PushTimeout:            ptr(15),
+		PushRetries:            ptr(3),
+		MaxLabelValueLength:    ptr(256),
+		DuplicateMetricPolicy:  ptr("ignore"),
+		MaxActionReasons:       ptr(20),
+		ExporterTimeoutSeconds: ptr(120),
+		SummaryEnabled:         ptr(true),
+		RunSummary:             ptr(true),
+		SummaryTimeout:         ptr(60),
+		GeminiMaxRetries:       ptr(2),
+		SummaryWordLimit:       ptr(250),
+		LogLevel:               ptr("info"),
+		LogFormat:              ptr("text"),
+	}
+}
+
+// readSettings parses a YAML or JSON config file over defaultSettings. Unknown
+// keys and values of the wrong type are errors.
+func readSettings(data []byte) (settings, error) {
+	s := defaultSettings()
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return settings{}, err
+	}
+	return s, nil
+}
+
+// environment renders every given setting as the value of its environment
+// variable; lists become comma-separated, like OUTPUT or DOWNTIME_RESOURCE_TYPES.
+func (s settings) environment() map[string]string {
+	env := map[string]string{}
+	v := reflect.ValueOf(s)
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if field.IsNil() {
+			continue
+		}
+		name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		if list, ok := field.Interface().(stringList); ok {
+			env[flagEnv(name)] = strings.Join(list, ",")
+		} else {
+			env[flagEnv(name)] = fmt.Sprint(field.Elem().Interface())
+		}
+	}
+	return env
+}
+
+// flagEnv returns the environment variable mirrored by the named flag of envFlags.
+func flagEnv(name string) string {
+	for _, ef := range envFlags {
+		if ef.name == name {
+			return ef.env
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads a YAML or JSON file whose keys are the flag names of
+// envFlags, e.g. "plan-path: plan.json". Each value is exported to the flag's
+// environment variable unless that variable is already set, so the environment
+// overrides the file and explicitly set flags (applied afterwards) override both.
+func loadConfigFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	s, err := readSettings(data)
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	for env, value := range s.environment() {
+		if _, set := os.LookupEnv(env); set {
+			continue
+		}
+		if err := os.Setenv(env, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSettingsCoverEnvFlags(t *testing.T) {
+	fields := map[string]bool{}
+	typ := reflect.TypeOf(settings{})
+	for i := 0; i < typ.NumField(); i++ {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+		if flagEnv(name) == "" {
+			t.Errorf("settings field %s has no flag %q", typ.Field(i).Name, name)
+		}
+		fields[name] = true
+	}
+	for _, ef := range envFlags {
+		if !fields[ef.name] {
+			t.Errorf("flag %q has no settings field", ef.name)
+		}
+	}
+}
+
+func TestReadSettings(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"yaml", "plan-path: plan.json\npush-retries: 5\nforce-push: true\noutput: [pushgateway, textfile]\n"},
+		{"json", `{"plan-path": "plan.json", "push-retries": 5, "force-push": true, "output": ["pushgateway", "textfile"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := readSettings([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("readSettings: %v", err)
+			}
+			env := s.environment()
+			want := map[string]string{
+				"TERRAFORM_PLAN_PATH": "plan.json",
+				"PUSH_RETRIES":        "5",
+				"FORCE_PUSH":          "true",
+				"OUTPUT":              "pushgateway,textfile",
+				// Left out of the file, so taken from defaultSettings
+				"PUSH_TIMEOUT_SECONDS": "15",
+			}
+			for name, value := range want {
+				if env[name] != value {
+					t.Errorf("%s = %q, want %q", name, env[name], value)
+				}
+			}
+			if _, ok := env["TEXTFILE_PATH"]; ok {
+				t.Errorf("TEXTFILE_PATH set without being given")
+			}
+		})
+	}
+}
+
+func TestReadSettingsScalarList(t *testing.T) {
+	s, err := readSettings([]byte("output: pushgateway,textfile\n"))
+	if err != nil {
+		t.Fatalf("readSettings: %v", err)
+	}
+	if got := s.environment()["OUTPUT"]; got != "pushgateway,textfile" {
+		t.Errorf("OUTPUT = %q", got)
+	}
+}
+
+func TestReadSettingsEmpty(t *testing.T) {
+	s, err := readSettings(nil)
+	if err != nil {
+		t.Fatalf("readSettings: %v", err)
+	}
+	if !reflect.DeepEqual(s, defaultSettings()) {
+		t.Errorf("empty file = %+v, want the defaults", s)
+	}
+}
+
+func TestReadSettingsRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unknown key", "plan-pth: plan.json\n"},
+		{"secret", "pushgateway-password: hunter2\n"},
+		{"int as word", "push-retries: three\n"},
+		{"bool as word", "force-push: sometimes\n"},
+		{"list of maps", "output: [{name: pushgateway}]\n"},
+		{"json unknown key", `{"plan-pth": "plan.json"}`},
+		{"json wrong type", `{"push-timeout": "soon"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := readSettings([]byte(tt.data)); err == nil {
+				t.Errorf("readSettings(%q) succeeded", tt.data)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFileEnvironmentWins(t *testing.T) {
+	path := writeConfig(t, "job: from-file\nplan-path: plan.json\n")
+	unsetFlagEnv(t)
+	t.Setenv("PUSHGATEWAY_JOB", "from-env")
+
+	if err := loadConfigFile(path); err != nil {
+		t.Fatalf("loadConfigFile: %v", err)
+	}
+	if got := os.Getenv("PUSHGATEWAY_JOB"); got != "from-env" {
+		t.Errorf("PUSHGATEWAY_JOB = %q, want the environment value", got)
+	}
+	if got := os.Getenv("TERRAFORM_PLAN_PATH"); got != "plan.json" {
+		t.Errorf("TERRAFORM_PLAN_PATH = %q, want the file value", got)
+	}
+}
+
+// writeConfig writes a config file into a temporary directory.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "settings.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// unsetFlagEnv unsets the variables of every flag for the duration of the test.
+func unsetFlagEnv(t *testing.T) {
+	t.Helper()
+	for _, ef := range envFlags {
+		t.Setenv(ef.env, "")
+		os.Unsetenv(ef.env)
+	}
+}
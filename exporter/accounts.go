@@ -0,0 +1,45 @@
+package exporter
+
+import "strings"
+
+// Top-level resource attributes that identify an AWS account or GCP project.
+var accountAttributes = []string{"account_id", "owner_id", "project", "project_id"}
+
+// resourceAccounts extracts the account/project identifiers a resource change refers
+// to, from well-known attributes and from an "arn" attribute. Unknown values yield none.
+func resourceAccounts(rc ResourceChange) []string {
+	attrs, ok := rc.Change.After.(map[string]interface{})
+	if !ok {
+		attrs, ok = rc.Change.Before.(map[string]interface{})
+	}
+	if !ok {
+		return nil
+	}
+
+	var ids []string
+	for _, name := range accountAttributes {
+		if v, ok := attrs[name].(string); ok && v != "" {
+			ids = append(ids, v)
+		}
+	}
+	// arn:partition:service:region:account-id:resource
+	if arn, ok := attrs["arn"].(string); ok {
+		parts := strings.SplitN(arn, ":", 6)
+		if len(parts) == 6 && parts[0] == "arn" && parts[4] != "" {
+			ids = append(ids, parts[4])
+		}
+	}
+	return ids
+}
+
+// countDistinctAccounts returns the number of distinct accounts/projects in the
+// plan's resource changes, no-ops included, or 0 when none can be determined.
+func countDistinctAccounts(changes []ResourceChange) int {
+	seen := map[string]bool{}
+	for _, rc := range changes {
+		for _, id := range resourceAccounts(rc) {
+			seen[id] = true
+		}
+	}
+	return len(seen)
+}
@@ -0,0 +1,59 @@
+package exporter
+
+import "sort"
+
+// defaultMaxActionReasons caps the number of distinct action_reason label values.
+const defaultMaxActionReasons = 20
+
+// countActionReasons counts resource changes per action_reason. Once maxReasons
+// distinct reasons have been seen (in alphabetical order), the remainder are folded
+// into "other" to bound label cardinality.
+func countActionReasons(changes []ResourceChange, maxReasons int) map[string]int {
+	raw := map[string]int{}
+	for _, rc := range changes {
+		if rc.ActionReason != "" {
+			raw[rc.ActionReason]++
+		}
+	}
+	if len(raw) <= maxReasons {
+		return raw
+	}
+
+	reasons := make([]string, 0, len(raw))
+	for reason := range raw {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	capped := map[string]int{}
+	for i, reason := range reasons {
+		if i < maxReasons {
+			capped[reason] = raw[reason]
+		} else {
+			capped["other"] += raw[reason]
+		}
+	}
+	return capped
+}
+
+// taintedActionReason is the action_reason of a replacement forced by `terraform taint`
+// or a failed create.
+const taintedActionReason = "replace_because_tainted"
+
+// countReplaceReasons counts planned replacements per action_reason, e.g.
+// replace_because_tainted or replace_because_cannot_update. Replacements without a
+// reason are counted as "unspecified".
+func countReplaceReasons(changes []ResourceChange) map[string]int {
+	counts := map[string]int{}
+	for _, rc := range changes {
+		if !isReplace(rc.Change.Actions) {
+			continue
+		}
+		reason := rc.ActionReason
+		if reason == "" {
+			reason = "unspecified"
+		}
+		counts[reason]++
+	}
+	return counts
+}
@@ -0,0 +1,42 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+const taintedPlanJSON = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "aws_instance.web", "mode": "managed", "type": "aws_instance",
+     "change": {"actions": ["delete", "create"]}, "action_reason": "replace_because_tainted"},
+    {"address": "aws_instance.api", "mode": "managed", "type": "aws_instance",
+     "change": {"actions": ["create", "delete"]}, "action_reason": "replace_because_tainted"},
+    {"address": "aws_db_instance.main", "mode": "managed", "type": "aws_db_instance",
+     "change": {"actions": ["delete", "create"]}, "action_reason": "replace_because_cannot_update"},
+    {"address": "aws_lb.front", "mode": "managed", "type": "aws_lb",
+     "change": {"actions": ["delete", "create"]}},
+    {"address": "aws_s3_bucket.old", "mode": "managed", "type": "aws_s3_bucket",
+     "change": {"actions": ["delete"]}, "action_reason": "delete_because_no_resource_config"}
+  ]
+}`
+
+func TestTaintedResources(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	m := parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", taintedPlanJSON)})
+
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+	if got := values["terraform_tainted_resources"]; len(got) != 1 || got[0] != 2 {
+		t.Errorf("terraform_tainted_resources = %v, want [2]", got)
+	}
+	// Only replacements count; the plain delete's reason is left out
+	want := map[string]int{
+		"replace_because_tainted":       2,
+		"replace_because_cannot_update": 1,
+		"unspecified":                   1,
+	}
+	if !reflect.DeepEqual(m.ReplaceReasons, want) {
+		t.Errorf("terraform_replace_reasons = %v, want %v", m.ReplaceReasons, want)
+	}
+}
@@ -0,0 +1,733 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics is everything the exporter derives from one Terraform run, before it is
+// turned into Prometheus collectors. Fields that only exist for some inputs are
+// guarded by PlanLoaded, PlanFromLog and HasApply.
+type Metrics struct {
+	ExecutionDuration float64
+	Timestamp         float64
+
+	// PlanLoaded is set when the plan JSON was read; PlanFromLog when the counts
+	// came from the text plan log instead.
+	PlanLoaded  bool
+	PlanFromLog bool
+	// PlanParseError is set when a plan JSON path was given but could not be read
+	// or parsed; an unset path is not an error.
+	PlanParseError bool
+	// PlanEmpty is set when the plan JSON parsed and changes no resources.
+	PlanEmpty bool
+	// PlanAge is the plan's age in seconds at push time, -1 without a timestamp.
+	PlanAge float64
+	// PlanUnknownFields is -1 unless PLAN_SCHEMA_CHECK ran successfully.
+	PlanUnknownFields   int
+	PlanBytes           int
+	PlanResourceChanges int
+
+	// ResourcesTotal counts changing resources; ManagedResources every managed
+	// resource in the plan, no-ops included.
+	ResourcesTotal   int
+	ManagedResources int
+	ToAdd            int
+	ToChange         int
+	ToDestroy        int
+	ToImport         int
+	ToReplace        int
+	// CBDReplacements and StandardReplacements split ToReplace by create_before_destroy.
+	CBDReplacements      int
+	StandardReplacements int
+	OutputsChanged       int
+	DowntimeChanges      int
+	// DowntimeAddresses lists the replacements counted in DowntimeChanges.
+	DowntimeAddresses []string
+	DistinctAccounts  int
+	DistinctProviders int
+	DistinctModules   int
+	SensitiveChanges  int
+	DataReads         int
+	UnknownRatio      float64
+	// ResourcesMoved is -1 when neither the plan JSON nor the plan log was available.
+	ResourcesMoved int
+	ChangesByType  map[typeAction]int
+	// ChangesByProvider is keyed by provider rather than resource type.
+	ChangesByProvider     map[typeAction]int
+	ChangesByActionReason map[string]int
+	// ReplaceReasons counts planned replacements by action_reason.
+	ReplaceReasons   map[string]int
+	TaintedResources int
+
+	DriftDetected bool
+	// DriftResourceCount is -1 when drift came from the refresh log rather than the plan.
+	DriftResourceCount int
+	// DriftedAddresses lists the drifted resources, from the plan or the refresh log.
+	DriftedAddresses []string
+	RefreshDuration  float64
+
+	HasApply bool
+	// RunType is "apply" when the apply log holds an apply summary, else "plan".
+	RunType          string
+	Added            int
+	Changed          int
+	Destroyed        int
+	Imported         int
+	SlowOperations   int
+	ThrottlingEvents int
+	// SlowestResource is empty when the apply log has no per-resource timing.
+	SlowestResource        string
+	SlowestResourceSeconds float64
+
+	// SecurityFindings is nil when no security scan was read.
+	SecurityFindings map[string]int
+	// StateResourcesByType counts managed resources per type in the state JSON; nil
+	// when none was read.
+	StateResourcesByType map[string]int
+	// ProviderVersions maps provider source address to the locked version; nil when
+	// no lock file was read.
+	ProviderVersions map[string]string
+	// ProviderUpgraded is 1 when a provider version changed since the previous run,
+	// -1 when there is no previous run to compare with.
+	ProviderUpgraded int
+	// EstimatedCostDelta is the Infracost monthly cost delta; HasCostEstimate is
+	// false when no report was read.
+	EstimatedCostDelta float64
+	HasCostEstimate    bool
+	ConditionFailures  int
+	TerraformVersion   string
+	Warnings           int
+	ErrorCategories    map[string]int
+	// PlanApplyDelta is the absolute difference between planned and applied counts
+	// per action ("add", "change", "destroy"); nil unless both plan and apply data
+	// were read.
+	PlanApplyDelta map[string]int
+	// StateLockFailure is set when the plan or apply could not acquire the state lock.
+	StateLockFailure bool
+	// PartialApply is set when the apply failed after completing some operations.
+	PartialApply bool
+	// PlanResult and ApplyResult are 1/0 per phase, -1 when its log is missing.
+	PlanResult  int
+	ApplyResult int
+	Succeeded   bool
+}
+
+// parseMetrics reads the run's plan and logs and computes its Metrics.
+func parseMetrics(logs runLogs) Metrics {
+	m := Metrics{ProviderUpgraded: -1, PlanUnknownFields: -1, ResourcesMoved: -1, DriftResourceCount: -1, UnknownRatio: -1, PlanAge: -1}
+
+	startUnix, _ := strconv.ParseInt(os.Getenv("TERRAFORM_START_TIME"), 10, 64)
+	m.ExecutionDuration = time.Since(time.Unix(startUnix, 0)).Seconds()
+	m.Timestamp = float64(time.Now().Unix())
+
+	// Plan-only data
+	var plan PlanJSON
+	var planFile []byte
+	if logs.planJSON != "" {
+		var planErr error
+		planFile, planErr = readLog(logs.planJSON)
+		if planErr == nil {
+			planErr = json.Unmarshal(planFile, &plan)
+		}
+		m.PlanLoaded = planErr == nil
+		m.PlanParseError = planErr != nil
+		if m.PlanParseError {
+			slog.Warn("could not load plan JSON, skipping plan metrics", "path", logs.planJSON, "error", planErr)
+		}
+	}
+
+	if m.PlanLoaded && os.Getenv("PLAN_SCHEMA_CHECK") == "true" {
+		if unknownFields, err := countUnknownPlanFields(planFile); err != nil {
+			slog.Warn("plan schema check failed", "error", err)
+		} else {
+			m.PlanUnknownFields = unknownFields
+		}
+	}
+
+	// Tally resource changes
+	changed, withUnknown := 0, 0
+	// Replacements are reported as terraform_to_replace only, unless the old
+	// behaviour of also counting them as an add and a destroy is requested.
+	replaceAsAddDestroy := os.Getenv("COUNT_REPLACE_AS_ADD_DESTROY") == "true"
+	for _, rc := range plan.ResourceChanges {
+		actions := rc.Change.Actions
+		// Data sources are read, never created, whatever their actions say
+		if rc.Mode == "data" || contains(actions, "read") {
+			m.DataReads++
+			continue
+		}
+		m.ManagedResources++
+		if !contains(actions, "no-op") {
+			changed++
+			m.ResourcesTotal++
+			if hasUnknownValues(rc.Change.AfterUnknown) {
+				withUnknown++
+			}
+			if hasSensitiveChange(rc) {
+				m.SensitiveChanges++
+			}
+		}
+		if isReplace(actions) {
+			m.ToReplace++
+			// ["create","delete"] is create_before_destroy, ["delete","create"] the default order
+			if actions[0] == "create" {
+				m.CBDReplacements++
+			} else {
+				m.StandardReplacements++
+			}
+			if !replaceAsAddDestroy {
+				continue
+			}
+		}
+		if contains(actions, "create") {
+			m.ToAdd++
+		}
+		if contains(actions, "update") {
+			m.ToChange++
+		}
+		if contains(actions, "delete") {
+			m.ToDestroy++
+		}
+		if contains(actions, "import") {
+			m.ToImport++
+		}
+	}
+
+	for _, oc := range plan.OutputChanges {
+		if contains(oc.Actions, "create") || contains(oc.Actions, "update") || contains(oc.Actions, "delete") {
+			m.OutputsChanged++
+		}
+	}
+
+	// Without plan JSON, fall back to the "Plan:" summary line of the text plan log
+	if !m.PlanLoaded && logs.planLog != "" {
+		if a, c, d, i, ok := parsePlanLogStats(logs.planLog); ok {
+			m.ToAdd, m.ToChange, m.ToDestroy, m.ToImport = a, c, d, i
+			m.ResourcesTotal = a + c + d + i
+			m.PlanFromLog = true
+		}
+	}
+
+	m.PlanEmpty = m.PlanLoaded && changed == 0
+
+	// Fraction of changing resources with known-after-apply values, -1 when nothing changes
+	if changed > 0 {
+		m.UnknownRatio = float64(withUnknown) / float64(changed)
+	}
+
+	if plan.Timestamp != "" {
+		if parsedTime, layout, ok := parseTimestamp(plan.Timestamp); ok {
+			slog.Debug("parsed plan timestamp", "timestamp", plan.Timestamp, "layout", layout)
+			m.Timestamp = float64(parsedTime.Unix())
+			m.PlanAge = time.Since(parsedTime).Seconds()
+		} else {
+			slog.Warn("unrecognised plan timestamp, using the current time", "timestamp", plan.Timestamp)
+		}
+	}
+
+	// With a plan, drift is derived from its resource_drift section; otherwise the
+	// refresh log is scanned.
+	if m.PlanLoaded {
+		m.DriftResourceCount = countDriftedResources(plan.ResourceDrift)
+		m.DriftDetected = m.DriftResourceCount > 0
+		m.DriftedAddresses = driftedAddresses(plan.ResourceDrift)
+	} else {
+		m.DriftDetected = detectDrift(logs.refreshLog) == 1
+		m.DriftedAddresses = driftedAddressesFromLog(logs.refreshLog)
+	}
+	m.RefreshDuration = jsonLogDuration(logs.refreshLog)
+
+	if m.PlanLoaded {
+		downtimeTypes := envList("DOWNTIME_RESOURCE_TYPES", defaultDowntimeResourceTypes)
+		m.DowntimeAddresses = downtimeAddresses(plan.ResourceChanges, downtimeTypes)
+		m.DowntimeChanges = len(m.DowntimeAddresses)
+		m.DistinctAccounts = countDistinctAccounts(plan.ResourceChanges)
+		m.DistinctProviders = countDistinctProviders(plan.ResourceChanges)
+		m.DistinctModules = countDistinctModules(plan.ResourceChanges)
+		m.ResourcesMoved = countMovedResources(plan.ResourceChanges)
+		m.PlanBytes = len(planFile)
+		m.PlanResourceChanges = len(plan.ResourceChanges)
+		m.ChangesByType = countChangesByType(plan.ResourceChanges)
+		m.ChangesByProvider = countChangesByProvider(plan.ResourceChanges)
+		m.ChangesByActionReason = countActionReasons(plan.ResourceChanges, envInt("MAX_ACTION_REASONS", defaultMaxActionReasons))
+		m.ReplaceReasons = countReplaceReasons(plan.ResourceChanges)
+		m.TaintedResources = m.ReplaceReasons[taintedActionReason]
+	} else if logs.planLog != "" {
+		m.ResourcesMoved = countMatchingLines(logs.planLog, []string{"has moved to"})
+	}
+
+	m.RunType = "plan"
+	if logs.applyLog != "" {
+		// Apply context
+		m.HasApply = true
+		if countMatchingLines(logs.applyLog, applySummaryPatterns) > 0 {
+			m.RunType = "apply"
+		}
+		m.Added, m.Changed, m.Destroyed, m.Imported = parseLogStats(logs.applyLog)
+
+		throttlePatterns := envList("THROTTLE_PATTERNS", defaultThrottlePatterns)
+		slowPatterns := envList("SLOW_OPERATION_PATTERNS", defaultSlowOperationPatterns)
+		m.SlowOperations = countMatchingLines(logs.applyLog, slowPatterns)
+		m.ThrottlingEvents = countMatchingLines(logs.applyLog, throttlePatterns)
+		if addr, seconds, ok := slowestResource(logs.applyLog); ok {
+			m.SlowestResource, m.SlowestResourceSeconds = addr, seconds
+		}
+	}
+
+	if scanPath := os.Getenv("SECURITY_SCAN_PATH"); scanPath != "" {
+		counts, err := parseSecurityScan(scanPath)
+		if err != nil {
+			slog.Warn("skipping security scan results", "path", scanPath, "error", err)
+		} else {
+			m.SecurityFindings = counts
+		}
+	}
+
+	if statePath := os.Getenv("TERRAFORM_STATE_JSON_PATH"); statePath != "" {
+		counts, err := parseStateResources(statePath)
+		if err != nil {
+			slog.Warn("skipping state inventory", "path", statePath, "error", err)
+		} else {
+			m.StateResourcesByType = counts
+		}
+	}
+
+	if lockPath := os.Getenv("TERRAFORM_LOCK_FILE_PATH"); lockPath != "" {
+		versions, err := parseLockFile(lockPath)
+		if err != nil {
+			slog.Warn("skipping provider versions", "path", lockPath, "error", err)
+		} else {
+			m.ProviderVersions = versions
+		}
+	}
+	if statePath := os.Getenv("PROVIDER_VERSIONS_STATE_FILE"); statePath != "" && m.ProviderVersions != nil {
+		previous, ok, err := readProviderVersions(statePath)
+		if err != nil {
+			slog.Warn("could not read previous provider versions", "path", statePath, "error", err)
+		} else if ok {
+			m.ProviderUpgraded = int(boolGauge(providersUpgraded(previous, m.ProviderVersions)))
+		}
+	}
+
+	if costPath := os.Getenv("INFRACOST_JSON_PATH"); costPath != "" {
+		delta, ok, err := parseInfracost(costPath)
+		if err != nil {
+			slog.Warn("skipping cost estimate", "path", costPath, "error", err)
+		}
+		m.EstimatedCostDelta, m.HasCostEstimate = delta, ok
+	}
+
+	conditionPatterns := envList("CONDITION_FAILURE_PATTERNS", defaultConditionFailurePatterns)
+	for _, path := range []string{logs.planLog, logs.applyLog} {
+		if path != "" {
+			m.ConditionFailures += countMatchingLines(path, conditionPatterns)
+		}
+	}
+
+	// Terraform version: -json logs first, then the plan, then TERRAFORM_VERSION
+	for _, path := range []string{logs.applyLog, logs.planLog, logs.refreshLog} {
+		if m.TerraformVersion == "" && path != "" {
+			m.TerraformVersion = terraformVersionFromLog(path)
+		}
+	}
+	if m.TerraformVersion == "" {
+		m.TerraformVersion = plan.TerraformVersion
+	}
+	if m.TerraformVersion == "" {
+		m.TerraformVersion = os.Getenv("TERRAFORM_VERSION")
+	}
+
+	resultLogPath := logs.planJSON
+	if logs.applyLog != "" {
+		resultLogPath = logs.applyLog
+	}
+	runScan := scanRunLogs(resultLogPath)
+	m.Succeeded = runScan.success
+	m.Warnings = runScan.warnings
+	m.ErrorCategories = runScan.errorCategories
+	m.StateLockFailure = runScan.stateLock
+
+	// Per-phase results; the overall result is the AND of the phases whose logs exist
+	m.PlanResult, m.ApplyResult = -1, -1
+	if logs.planLog != "" {
+		if planScan := scanRunLog(logs.planLog); planScan.found {
+			m.PlanResult = int(boolGauge(planScan.success))
+			m.StateLockFailure = m.StateLockFailure || planScan.stateLock
+			if logs.planLog != resultLogPath {
+				m.Warnings += planScan.warnings
+				if m.ErrorCategories == nil {
+					m.ErrorCategories = map[string]int{}
+				}
+				for category, count := range planScan.errorCategories {
+					m.ErrorCategories[category] += count
+				}
+			}
+		}
+	}
+	if logs.applyLog != "" && runScan.found {
+		m.ApplyResult = int(boolGauge(runScan.success))
+	}
+	if m.PlanResult >= 0 || m.ApplyResult >= 0 {
+		m.Succeeded = m.PlanResult != 0 && m.ApplyResult != 0
+	}
+
+	// A failed apply that still completed some operations left the infrastructure
+	// half-changed. Without a final summary, count what did complete.
+	if m.ApplyResult == 0 {
+		added, changed, destroyed := completedOperations(logs.applyLog)
+		m.PartialApply = added+changed+destroyed > 0
+		if m.PartialApply && m.Added+m.Changed+m.Destroyed == 0 {
+			m.Added, m.Changed, m.Destroyed = added, changed, destroyed
+		}
+	}
+
+	// Compare plan and apply like for like: an apply counts a replacement as one
+	// add and one destroy, as does the text plan summary, but the plan JSON counts
+	// only report it as a replacement unless COUNT_REPLACE_AS_ADD_DESTROY is set.
+	if (m.PlanLoaded || m.PlanFromLog) && m.HasApply {
+		plannedAdd, plannedDestroy := m.ToAdd, m.ToDestroy
+		if m.PlanLoaded && !replaceAsAddDestroy {
+			plannedAdd += m.ToReplace
+			plannedDestroy += m.ToReplace
+		}
+		m.PlanApplyDelta = map[string]int{
+			"add":     absInt(plannedAdd - m.Added),
+			"change":  absInt(m.ToChange - m.Changed),
+			"destroy": absInt(plannedDestroy - m.Destroyed),
+		}
+	}
+	return m
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+var defaultDurationBuckets = []float64{30, 60, 120, 300, 600, 1200, 1800, 3600}
+
+// durationBuckets parses DURATION_BUCKETS values in seconds, skipping invalid ones.
+// It falls back to defaultDurationBuckets when none are valid.
+func durationBuckets(values []string) []float64 {
+	var buckets []float64
+	for _, v := range values {
+		b, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			slog.Warn("ignoring invalid duration bucket", "value", v)
+			continue
+		}
+		buckets = append(buckets, b)
+	}
+	if len(buckets) == 0 {
+		return defaultDurationBuckets
+	}
+	sort.Float64s(buckets)
+	return buckets
+}
+
+// boolGauge is the 0/1 value used for boolean gauges.
+func boolGauge(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// buildCollectors turns parsed Metrics into Prometheus collectors. A missing or
+// corrupt plan must not look like an empty one, so plan-derived gauges are left out
+// rather than reported as zero.
+func buildCollectors(m Metrics, metrics *metricRegistry) []prometheus.Collector {
+	var collectors []prometheus.Collector
+
+	if m.PlanUnknownFields >= 0 {
+		metrics.Add("terraform_plan_unknown_fields", "Plan JSON fields not in the documented plan format", float64(m.PlanUnknownFields))
+	}
+
+	// Export common metrics
+	metrics.Add("terraform_execution_duration_seconds", "Time taken for execution", m.ExecutionDuration)
+	metrics.Add("terraform_timestamp", "Unix timestamp of run", m.Timestamp)
+	if os.Getenv("DURATION_HISTOGRAM") == "true" {
+		histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    metricName("terraform_execution_duration"),
+			Help:    "Time taken for execution in seconds, as a histogram",
+			Buckets: durationBuckets(envList("DURATION_BUCKETS", nil)),
+		})
+		histogram.Observe(m.ExecutionDuration)
+		collectors = append(collectors, histogram)
+	}
+	if m.DriftResourceCount >= 0 {
+		metrics.Add("terraform_drift_resource_count", "Resources that drifted outside Terraform", float64(m.DriftResourceCount))
+	}
+	metrics.Add("terraform_drift_detected", "Drift found during refresh", boolGauge(m.DriftDetected))
+	metrics.Add("terraform_refresh_duration_seconds", "Duration of the refresh phase from -json timestamps (-1 if unknown)", m.RefreshDuration)
+
+	metrics.Add("terraform_plan_parse_error", "1 if the given plan JSON was missing or unparseable", boolGauge(m.PlanParseError))
+	metrics.Add("terraform_plan_age_seconds", "Age of the plan at push time (-1 if unknown)", m.PlanAge)
+	metrics.Add("terraform_plan_empty", "1 if the plan JSON parsed and has only no-op resource changes", boolGauge(m.PlanEmpty))
+	if m.PlanLoaded || m.PlanFromLog {
+		metrics.Add("terraform_resources_total", "Resources planned to change, excluding no-ops", float64(m.ResourcesTotal))
+		metrics.Add("terraform_to_add", "Resources planned to be added", float64(m.ToAdd))
+		metrics.Add("terraform_to_change", "Resources planned to be changed", float64(m.ToChange))
+		metrics.Add("terraform_to_destroy", "Resources planned to be destroyed", float64(m.ToDestroy))
+		metrics.Add("terraform_to_import", "Resources planned to be imported", float64(m.ToImport))
+	}
+	if m.PlanLoaded {
+		metrics.Add("terraform_managed_resources_total", "Managed resources in the plan, unchanged ones included", float64(m.ManagedResources))
+		metrics.Add("terraform_outputs_changed", "Root module outputs planned to change", float64(m.OutputsChanged))
+		metrics.Add("terraform_to_replace", "Resources planned to be replaced", float64(m.ToReplace))
+		metrics.Add("terraform_cbd_replacements", "Planned replacements using create_before_destroy", float64(m.CBDReplacements))
+		metrics.Add("terraform_standard_replacements", "Planned replacements that destroy before creating", float64(m.StandardReplacements))
+		metrics.Add("terraform_tainted_resources", "Resources planned to be replaced because they are tainted", float64(m.TaintedResources))
+		metrics.Add("terraform_downtime_risk_changes", "Planned replacements of downtime-inducing resource types", float64(m.DowntimeChanges))
+		metrics.Add("terraform_distinct_accounts", "Distinct cloud accounts/projects in the plan's resource changes, no-ops included", float64(m.DistinctAccounts))
+		metrics.Add("terraform_distinct_providers", "Distinct providers in the plan's resource changes, no-ops included", float64(m.DistinctProviders))
+		metrics.Add("terraform_distinct_modules", "Distinct modules in the plan's resource changes, root and no-ops included", float64(m.DistinctModules))
+		metrics.Add("terraform_data_reads", "Data sources read during the plan", float64(m.DataReads))
+		metrics.Add("terraform_sensitive_changes", "Changing resources whose sensitive attributes change", float64(m.SensitiveChanges))
+		metrics.Add("terraform_unknown_ratio", "Fraction of changing resources with known-after-apply values (-1 if none change)", m.UnknownRatio)
+		metrics.Add("terraform_plan_bytes", "Size of the plan JSON in bytes, after decompression", float64(m.PlanBytes))
+		metrics.Add("terraform_plan_resource_changes_total", "Entries in the plan's resource_changes, including no-ops", float64(m.PlanResourceChanges))
+	}
+	if m.ResourcesMoved >= 0 {
+		metrics.Add("terraform_resources_moved", "Resources whose address changes through moved blocks", float64(m.ResourcesMoved))
+	}
+
+	if m.HasApply {
+		metrics.Add("terraform_added", "Resources actually added", float64(m.Added))
+		metrics.Add("terraform_changed", "Resources actually changed", float64(m.Changed))
+		metrics.Add("terraform_destroyed", "Resources actually destroyed", float64(m.Destroyed))
+		metrics.Add("terraform_imported", "Resources actually imported", float64(m.Imported))
+		metrics.Add("terraform_slow_operations_total", "Still-in-progress status lines in the apply log", float64(m.SlowOperations))
+		metrics.Add("terraform_partial_apply", "1 if the apply failed after completing some resource operations", boolGauge(m.PartialApply))
+		metrics.Add("terraform_provider_throttling_events", "Provider API throttling lines in the apply log", float64(m.ThrottlingEvents))
+	}
+
+	runType := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        metricName("terraform_run_type"),
+		Help:        "Always 1; run_type is plan for plan-only runs and apply for applies",
+		ConstLabels: prometheus.Labels{"run_type": m.RunType},
+	})
+	runType.Set(1)
+	collectors = append(collectors, runType, buildInfoCollector())
+
+	if m.SlowestResource != "" {
+		slowest := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        metricName("terraform_slowest_resource_seconds"),
+			Help:        "Apply time of the slowest resource in the run",
+			ConstLabels: prometheus.Labels{"address": truncateLabelValue(m.SlowestResource, envInt("MAX_LABEL_VALUE_LENGTH", defaultMaxLabelValueLength))},
+		})
+		slowest.Set(m.SlowestResourceSeconds)
+		collectors = append(collectors, slowest)
+	}
+
+	if m.SecurityFindings != nil {
+		findings := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("terraform_security_findings"),
+			Help: "Failed security scan checks by severity",
+		}, []string{"severity"})
+		for severity, count := range m.SecurityFindings {
+			findings.WithLabelValues(severity).Set(float64(count))
+		}
+		collectors = append(collectors, findings)
+	}
+
+	if m.StateResourcesByType != nil {
+		stateResources := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("terraform_state_resources"),
+			Help: "Managed resources in the state by resource type",
+		}, []string{"type"})
+		total := 0
+		for resourceType, count := range m.StateResourcesByType {
+			stateResources.WithLabelValues(resourceType).Set(float64(count))
+			total += count
+		}
+		collectors = append(collectors, stateResources)
+		metrics.Add("terraform_state_resources_total", "Managed resources in the state", float64(total))
+	}
+
+	if m.ProviderVersions != nil {
+		providerVersions := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("terraform_provider_version_info"),
+			Help: "Always 1; the provider version selected in the dependency lock file",
+		}, []string{"provider", "version"})
+		for provider, version := range m.ProviderVersions {
+			providerVersions.WithLabelValues(provider, version).Set(1)
+		}
+		collectors = append(collectors, providerVersions)
+	}
+	if m.ProviderUpgraded >= 0 {
+		metrics.Add("terraform_provider_upgraded", "1 if a provider version changed since the previous run", float64(m.ProviderUpgraded))
+	}
+
+	if m.HasCostEstimate {
+		metrics.Add("terraform_estimated_cost_delta", "Estimated monthly cost change from Infracost", m.EstimatedCostDelta)
+	}
+
+	metrics.Add("terraform_condition_failures_total", "Failed precondition/postcondition checks in the logs", float64(m.ConditionFailures))
+
+	if m.TerraformVersion != "" {
+		versionInfo := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        metricName("terraform_version_info"),
+			Help:        "Terraform core version used for the run",
+			ConstLabels: prometheus.Labels{"version": m.TerraformVersion},
+		})
+		versionInfo.Set(1)
+		collectors = append(collectors, versionInfo)
+	}
+
+	if m.PlanLoaded {
+		collectors = append(collectors, resourceChangeCollectors(m.ChangesByType)...)
+
+		byProvider := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("terraform_provider_changes"),
+			Help: "Planned resource changes by provider and action",
+		}, []string{"provider", "action"})
+		for key, count := range m.ChangesByProvider {
+			byProvider.WithLabelValues(key.resourceType, key.action).Set(float64(count))
+		}
+		collectors = append(collectors, byProvider)
+
+		byReason := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("terraform_changes_by_action_reason"),
+			Help: "Planned resource changes by Terraform action_reason",
+		}, []string{"reason"})
+		for reason, count := range m.ChangesByActionReason {
+			byReason.WithLabelValues(reason).Set(float64(count))
+		}
+		collectors = append(collectors, byReason)
+
+		replaceReasons := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("terraform_replace_reasons"),
+			Help: "Planned replacements by Terraform action_reason",
+		}, []string{"reason"})
+		for reason, count := range m.ReplaceReasons {
+			replaceReasons.WithLabelValues(reason).Set(float64(count))
+		}
+		collectors = append(collectors, replaceReasons)
+	}
+
+	metrics.Add("terraform_warnings_total", "Warnings reported in the plan/apply log", float64(m.Warnings))
+	errorsByCategory := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: metricName("terraform_error_category"),
+		Help: "Error blocks in the plan/apply log by category",
+	}, []string{"category"})
+	for category, count := range m.ErrorCategories {
+		errorsByCategory.WithLabelValues(category).Set(float64(count))
+	}
+	collectors = append(collectors, errorsByCategory)
+	if m.PlanResult >= 0 {
+		metrics.Add("terraform_plan_result", "1=plan succeeded, 0=plan failed", float64(m.PlanResult))
+	}
+	if m.ApplyResult >= 0 {
+		metrics.Add("terraform_apply_result", "1=apply succeeded, 0=apply failed", float64(m.ApplyResult))
+	}
+	if m.PlanApplyDelta != nil {
+		delta := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: metricName("terraform_plan_apply_delta"),
+			Help: "Absolute difference between planned and applied resource counts by action",
+		}, []string{"action"})
+		mismatch := false
+		for action, count := range m.PlanApplyDelta {
+			delta.WithLabelValues(action).Set(float64(count))
+			mismatch = mismatch || count != 0
+		}
+		collectors = append(collectors, delta)
+		metrics.Add("terraform_plan_apply_mismatch", "1 if the applied resource counts differ from the plan", boolGauge(mismatch))
+	}
+
+	metrics.Add("terraform_state_lock_failure", "1 if the run failed to acquire the state lock", boolGauge(m.StateLockFailure))
+	metrics.Add("terraform_result", "1=success, 0=failure", boolGauge(m.Succeeded))
+
+	// Added last so that a name clashing with a built-in gauge never replaces it.
+	if path := os.Getenv("EXTRA_METRICS_FILE"); path != "" {
+		extras, err := parseExtraMetrics(path)
+		if err != nil {
+			slog.Warn("skipping extra metrics", "path", path, "error", err)
+		}
+		for _, e := range extras {
+			if name := metricName(e.name); metrics.Has(name) || isBuiltinMetric(name) {
+				slog.Warn("skipping extra metric that clashes with a built-in metric", "metric", name)
+				continue
+			}
+			metrics.Add(e.name, "Custom metric from EXTRA_METRICS_FILE", e.value)
+		}
+	}
+
+	return append(collectors, metrics.Collectors()...)
+}
+
+// lastSuccessCollector returns the terraform_last_success_timestamp gauge, or nil
+// when the run failed so the previous success is kept.
+func lastSuccessCollector(m Metrics) prometheus.Collector {
+	if !m.Succeeded {
+		return nil
+	}
+	g := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: metricName("terraform_last_success_timestamp"),
+		Help: "Unix timestamp of the last successful run",
+	})
+	g.Set(m.Timestamp)
+	return g
+}
+
+// pushMetrics writes the collectors to every configured output, or to stdout in a
+// dry run.
+func pushMetrics(ctx context.Context, outputs []string, isDryRun bool, collectors []prometheus.Collector, lastSuccess prometheus.Collector) error {
+	grouping := groupingFromEnv()
+
+	include, exclude := envList("METRICS_INCLUDE", nil), envList("METRICS_EXCLUDE", nil)
+	collectors = filterCollectors(collectors, include, exclude)
+	if lastSuccess != nil && len(filterCollectors([]prometheus.Collector{lastSuccess}, include, exclude)) == 0 {
+		lastSuccess = nil
+	}
+
+	var sinks []MetricSink
+	for _, output := range outputs {
+		switch output {
+		case "pushgateway":
+			sinks = append(sinks, newPushgatewaySinks(grouping, lastSuccess))
+		case "remote_write":
+			sinks = append(sinks, &remoteWriteSink{
+				url:      os.Getenv("REMOTE_WRITE_URL"),
+				tenant:   os.Getenv("REMOTE_WRITE_TENANT"),
+				username: os.Getenv("REMOTE_WRITE_USERNAME"),
+				password: os.Getenv("REMOTE_WRITE_PASSWORD"),
+				labels:   grouping.labels,
+			})
+		case "textfile":
+			path := os.Getenv("TEXTFILE_PATH")
+			if path == "" {
+				path = "terraform.prom"
+			}
+			sinks = append(sinks, textfileSink{path: path})
+		default:
+			return fmt.Errorf("%w: unknown OUTPUT %q", ErrMissingConfig, output)
+		}
+	}
+	if isDryRun {
+		sinks = []MetricSink{stdoutSink{job: grouping.job, grouping: grouping.labels}}
+	}
+	// The JSON dump is independent of the outputs and written even in a dry run
+	if path := os.Getenv("OUTPUT_JSON_PATH"); path != "" {
+		all := collectors
+		if lastSuccess != nil {
+			all = append(append([]prometheus.Collector{}, collectors...), lastSuccess)
+		}
+		dump := jsonSink{path: path, job: grouping.job, grouping: grouping.labels}
+		if err := dump.Write(ctx, all); err != nil {
+			slog.Warn("could not write JSON metrics", "path", path, "error", err)
+		}
+	}
+	return writeToSinks(ctx, sinks, collectors, os.Getenv("OUTPUT_REQUIRE_ALL") == "true")
+}
@@ -0,0 +1,478 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const replacePlanJSON = `{
+  "format_version": "1.2",
+  "terraform_version": "1.9.5",
+  "resource_changes": [
+    {"address": "aws_instance.web", "mode": "managed", "type": "aws_instance", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["delete", "create"]}},
+    {"address": "aws_lb.front", "mode": "managed", "type": "aws_lb", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["create", "delete"]}},
+    {"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["create"]}},
+    {"address": "aws_iam_role.ci", "mode": "managed", "type": "aws_iam_role", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["update"]}},
+    {"address": "aws_vpc.main", "mode": "managed", "type": "aws_vpc", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["no-op"]}},
+    {"address": "data.aws_caller_identity.current", "mode": "data", "type": "aws_caller_identity", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["read"]}}
+  ]
+}`
+
+// The apply creates one resource more than planned.
+const replaceApplyLog = `aws_instance.web: Destroying... [id=i-0123]
+aws_instance.web: Destruction complete after 30s
+aws_instance.web: Creating...
+aws_instance.web: Creation complete after 40s [id=i-4567]
+
+Apply complete! Resources: 4 added, 1 changed, 2 destroyed.
+`
+
+func TestParseMetricsReplacements(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("COUNT_REPLACE_AS_ADD_DESTROY", "")
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	logs := runLogs{
+		planJSON: writeTestFile(t, "plan.json", replacePlanJSON),
+		applyLog: writeTestFile(t, "apply.log", replaceApplyLog),
+	}
+
+	m := parseMetrics(logs)
+	if m.RunType != "apply" || !m.Succeeded {
+		t.Errorf("run type = %q, succeeded = %v, want a successful apply", m.RunType, m.Succeeded)
+	}
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+
+	for name, want := range map[string]float64{
+		"terraform_to_replace":            2,
+		"terraform_cbd_replacements":      1,
+		"terraform_standard_replacements": 1,
+		"terraform_to_add":                1,
+		"terraform_to_change":             1,
+		"terraform_to_destroy":            0,
+		"terraform_plan_parse_error":      0,
+		"terraform_plan_apply_mismatch":   1,
+		"terraform_result":                1,
+	} {
+		if got := values[name]; len(got) != 1 || got[0] != want {
+			t.Errorf("%s = %v, want [%v]", name, got, want)
+		}
+	}
+	// Replacements count as one add and one destroy each when compared with the
+	// apply: 3 planned adds against 4 applied
+	if got, want := values["terraform_plan_apply_delta"], []float64{1, 0, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("terraform_plan_apply_delta{add,change,destroy} = %v, want %v", got, want)
+	}
+}
+
+func TestParseMetricsResourceCounts(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	m := parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", replacePlanJSON)})
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+
+	// Six entries: four changing resources, one no-op and one data source
+	for name, want := range map[string]float64{
+		"terraform_resources_total":         4,
+		"terraform_managed_resources_total": 5,
+		"terraform_data_reads":              1,
+	} {
+		if got := values[name]; len(got) != 1 || got[0] != want {
+			t.Errorf("%s = %v, want [%v]", name, got, want)
+		}
+	}
+}
+
+func TestParseMetricsPlanSize(t *testing.T) {
+	tests := []struct {
+		name string
+		path func(*testing.T) string
+	}{
+		{"plain", func(t *testing.T) string { return writeTestFile(t, "plan.json", replacePlanJSON) }},
+		{"gzipped", func(t *testing.T) string { return writeGzip(t, t.TempDir(), "plan.json.gz", replacePlanJSON) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			m := parseMetrics(runLogs{planJSON: tt.path(t)})
+			values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+
+			// The size is measured after decompression; all six entries count, the no-op included
+			for name, want := range map[string]float64{
+				"terraform_plan_bytes":                  float64(len(replacePlanJSON)),
+				"terraform_plan_resource_changes_total": 6,
+			} {
+				if got := values[name]; len(got) != 1 || got[0] != want {
+					t.Errorf("%s = %v, want [%v]", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMetricsDataReads(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	plan := `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "change": {"actions": ["create"]}},
+    {"address": "data.aws_iam_policy_document.logs", "mode": "data", "type": "aws_iam_policy_document", "change": {"actions": ["read"]}},
+    {"address": "data.aws_ami.ubuntu", "mode": "data", "type": "aws_ami", "change": {"actions": ["create"]}}
+  ]
+}`
+	m := parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", plan)})
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+
+	// Data sources are reads whatever their actions say, never creates
+	for name, want := range map[string]float64{
+		"terraform_data_reads":      2,
+		"terraform_to_add":          1,
+		"terraform_resources_total": 1,
+	} {
+		if got := values[name]; len(got) != 1 || got[0] != want {
+			t.Errorf("%s = %v, want [%v]", name, got, want)
+		}
+	}
+}
+
+func TestParseMetricsOutputsChanged(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	plan := `{
+  "format_version": "1.2",
+  "resource_changes": [],
+  "output_changes": {
+    "bucket_arn": {"actions": ["create"], "before": null, "after": "arn:aws:s3:::logs"},
+    "endpoint": {"actions": ["update"], "before": "a.example", "after": "b.example"},
+    "region": {"actions": ["no-op"], "before": "eu-west-1", "after": "eu-west-1"}
+  }
+}`
+	m := parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", plan)})
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+	if got := values["terraform_outputs_changed"]; len(got) != 1 || got[0] != 2 {
+		t.Errorf("terraform_outputs_changed = %v, want [2]", got)
+	}
+}
+
+func TestParseMetricsWithoutPlanPath(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	m := parseMetrics(runLogs{applyLog: writeTestFile(t, "apply.log", replaceApplyLog)})
+	if m.PlanLoaded || m.PlanParseError {
+		t.Errorf("plan loaded = %v, parse error = %v, want neither without a plan path", m.PlanLoaded, m.PlanParseError)
+	}
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+	if got := values["terraform_plan_parse_error"]; len(got) != 1 || got[0] != 0 {
+		t.Errorf("terraform_plan_parse_error = %v, want [0]", got)
+	}
+	if _, ok := values["terraform_to_replace"]; ok {
+		t.Error("terraform_to_replace reported without a plan")
+	}
+}
+
+func TestParseMetricsPlanParseError(t *testing.T) {
+	tests := []struct {
+		name, content string
+	}{
+		{"empty file", ""},
+		{"invalid JSON", `{"resource_changes": [`},
+	}
+	paths := map[string]string{"missing file": filepath.Join(t.TempDir(), "plan.json")}
+	for _, tt := range tests {
+		paths[tt.name] = writeTestFile(t, "plan.json", tt.content)
+	}
+	for name, path := range paths {
+		t.Run(name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			m := parseMetrics(runLogs{planJSON: path})
+			if m.PlanLoaded || !m.PlanParseError {
+				t.Errorf("plan loaded = %v, parse error = %v, want a parse error", m.PlanLoaded, m.PlanParseError)
+			}
+			values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+			if got := values["terraform_plan_parse_error"]; len(got) != 1 || got[0] != 1 {
+				t.Errorf("terraform_plan_parse_error = %v, want [1]", got)
+			}
+			// No false zeros for a plan that could not be read
+			for _, name := range []string{"terraform_to_add", "terraform_to_replace", "terraform_resources_total"} {
+				if _, ok := values[name]; ok {
+					t.Errorf("%s reported for an unreadable plan", name)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMetricsRunWithoutPlanJSON(t *testing.T) {
+	clearEnv(t)
+	for _, name := range []string{"TERRAFORM_PLAN_PATH", "TERRAFORM_PLAN_LOG_PATH", "TERRAFORM_APPLY_LOG_PATH", "TERRAFORM_REFRESH_LOG_PATH", "EXTRA_METRICS_FILE"} {
+		t.Setenv(name, "")
+	}
+	dir := t.TempDir()
+	t.Setenv("TERRAFORM_LOG_DIR", dir)
+	planLog := "Plan: 2 to add, 1 to change, 0 to destroy.\n"
+	if err := os.WriteFile(filepath.Join(dir, "terraform-plan-7.log"), []byte(planLog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := parseMetrics(logsForRun("7"))
+	if m.PlanParseError || !m.PlanFromLog {
+		t.Errorf("parse error = %v, plan from log = %v, want the plan log used without an error", m.PlanParseError, m.PlanFromLog)
+	}
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+	for name, want := range map[string]float64{
+		"terraform_plan_parse_error": 0,
+		"terraform_to_add":           2,
+		"terraform_to_change":        1,
+	} {
+		if got := values[name]; len(got) != 1 || got[0] != want {
+			t.Errorf("%s = %v, want [%v]", name, got, want)
+		}
+	}
+}
+
+const failedPlanLog = `aws_s3_bucket.logs: Refreshing state... [id=logs]
+
+Error: reading S3 Bucket (logs): AccessDenied: Access Denied
+  with aws_s3_bucket.logs,
+  on main.tf line 1, in resource "aws_s3_bucket" "logs":
+`
+
+const failedApplyLog = `aws_instance.web: Creating...
+
+Error: creating EC2 Instance: InvalidAMIID.NotFound: The image id '[ami-1]' does not exist
+  with aws_instance.web,
+  on main.tf line 10, in resource "aws_instance" "web":
+`
+
+func TestParseMetricsPhaseResults(t *testing.T) {
+	okPlanLog := "Plan: 1 to add, 0 to change, 0 to destroy.\n"
+	tests := []struct {
+		name              string
+		planLog, applyLog string
+		// -1 means the phase's gauge is not reported
+		wantPlan, wantApply, wantResult float64
+	}{
+		{"plan failed, apply missing", failedPlanLog, "", 0, -1, 0},
+		{"plan ok, apply failed", okPlanLog, failedApplyLog, 1, 0, 0},
+		{"plan ok, apply ok", okPlanLog, replaceApplyLog, 1, 1, 1},
+		{"plan ok, apply missing", okPlanLog, "", 1, -1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			logs := runLogs{planLog: writeTestFile(t, "plan.log", tt.planLog)}
+			if tt.applyLog != "" {
+				logs.applyLog = writeTestFile(t, "apply.log", tt.applyLog)
+			}
+			values := gatherValues(t, buildCollectors(parseMetrics(logs), newMetricRegistry(nil, false)))
+
+			for name, want := range map[string]float64{
+				"terraform_plan_result":  tt.wantPlan,
+				"terraform_apply_result": tt.wantApply,
+				"terraform_result":       tt.wantResult,
+			} {
+				got, ok := values[name]
+				switch {
+				case want < 0 && ok:
+					t.Errorf("%s = %v, want it left out", name, got)
+				case want >= 0 && (len(got) != 1 || got[0] != want):
+					t.Errorf("%s = %v, want [%v]", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+const stateLockLog = `Acquiring state lock. This may take a few moments...
+
+Error: Error acquiring the state lock
+
+Error message: ConditionalCheckFailedException: The conditional request failed
+Lock Info:
+  ID:        8a1e3c2f-5b7d-4c1e-9f0a-2d6b8e4c7a13
+  Path:      acme-tfstate/prod/terraform.tfstate
+  Operation: OperationTypeApply
+  Who:       ci@runner-42
+
+Terraform acquires a state lock to protect the state from being written
+by multiple users at the same time.
+`
+
+func TestParseMetricsStateLockFailure(t *testing.T) {
+	okPlanLog := "Plan: 1 to add, 0 to change, 0 to destroy.\n"
+	tests := []struct {
+		name              string
+		planLog, applyLog string
+		wantLock          float64
+		wantResult        float64
+	}{
+		{"apply locked", okPlanLog, stateLockLog, 1, 0},
+		{"plan locked", stateLockLog, "", 1, 0},
+		{"other failure", okPlanLog, failedApplyLog, 0, 0},
+		{"no failure", okPlanLog, replaceApplyLog, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			logs := runLogs{planLog: writeTestFile(t, "plan.log", tt.planLog)}
+			if tt.applyLog != "" {
+				logs.applyLog = writeTestFile(t, "apply.log", tt.applyLog)
+			}
+			values := gatherValues(t, buildCollectors(parseMetrics(logs), newMetricRegistry(nil, false)))
+
+			for name, want := range map[string]float64{
+				"terraform_state_lock_failure": tt.wantLock,
+				"terraform_result":             tt.wantResult,
+			} {
+				if got := values[name]; len(got) != 1 || got[0] != want {
+					t.Errorf("%s = %v, want [%v]", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDurationHistogramBuckets(t *testing.T) {
+	tests := []struct {
+		name, buckets string
+		duration      float64
+		// want maps each upper bound to its cumulative count
+		want map[float64]uint64
+	}{
+		{"default buckets", "", 90, map[float64]uint64{30: 0, 60: 0, 120: 1, 300: 1, 600: 1, 1200: 1, 1800: 1, 3600: 1}},
+		{"on a bucket boundary", "", 300, map[float64]uint64{30: 0, 60: 0, 120: 0, 300: 1, 600: 1, 1200: 1, 1800: 1, 3600: 1}},
+		{"DURATION_BUCKETS, unsorted with an invalid value", "100,10,abc", 42, map[float64]uint64{10: 0, 100: 1}},
+		{"above every bucket", "10,100", 4000, map[float64]uint64{10: 0, 100: 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("DURATION_HISTOGRAM", "true")
+			t.Setenv("DURATION_BUCKETS", tt.buckets)
+
+			reg := prometheus.NewPedanticRegistry()
+			for _, c := range buildCollectors(Metrics{ExecutionDuration: tt.duration}, newMetricRegistry(nil, false)) {
+				if err := reg.Register(c); err != nil {
+					t.Fatalf("register: %v", err)
+				}
+			}
+			families, err := reg.Gather()
+			if err != nil {
+				t.Fatalf("gather: %v", err)
+			}
+			var histogram *dto.Histogram
+			for _, mf := range families {
+				if mf.GetName() == "terraform_execution_duration" {
+					histogram = mf.GetMetric()[0].GetHistogram()
+				}
+			}
+			if histogram == nil {
+				t.Fatal("terraform_execution_duration not gathered")
+			}
+			got := map[float64]uint64{}
+			for _, b := range histogram.GetBucket() {
+				got[b.GetUpperBound()] = b.GetCumulativeCount()
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buckets = %v, want %v", got, tt.want)
+			}
+			if histogram.GetSampleCount() != 1 || histogram.GetSampleSum() != tt.duration {
+				t.Errorf("count = %d, sum = %v, want one observation of %v", histogram.GetSampleCount(), histogram.GetSampleSum(), tt.duration)
+			}
+		})
+	}
+}
+
+func TestParseMetricsRunType(t *testing.T) {
+	tests := []struct {
+		name     string
+		applyLog string
+		want     string
+	}{
+		{"no apply log", "", "plan"},
+		{"apply log with a summary", replaceApplyLog, "apply"},
+		{"json apply log", timedApplyLog, "apply"},
+		{"apply log without a summary", failedApplyLog, "plan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			logs := runLogs{planLog: writeTestFile(t, "plan.log", "Plan: 1 to add, 0 to change, 0 to destroy.\n")}
+			if tt.applyLog != "" {
+				logs.applyLog = writeTestFile(t, "apply.log", tt.applyLog)
+			}
+			got := gatherLabels(t, buildCollectors(parseMetrics(logs), newMetricRegistry(nil, false)), "terraform_run_type")
+			if want := []map[string]string{{"run_type": tt.want}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("terraform_run_type labels = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseMetricsPlanEmpty(t *testing.T) {
+	tests := []struct {
+		name, changes string
+		want          float64
+	}{
+		{"empty plan", ``, 1},
+		{"no-op only", `{"address": "aws_vpc.main", "mode": "managed", "type": "aws_vpc", "change": {"actions": ["no-op"]}},
+		  {"address": "data.aws_caller_identity.current", "mode": "data", "type": "aws_caller_identity", "change": {"actions": ["read"]}}`, 1},
+		{"real changes", `{"address": "aws_vpc.main", "mode": "managed", "type": "aws_vpc", "change": {"actions": ["no-op"]}},
+		  {"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "change": {"actions": ["create"]}}`, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			plan := `{"format_version": "1.2", "resource_changes": [` + tt.changes + `]}`
+			values := gatherValues(t, buildCollectors(parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", plan)}), newMetricRegistry(nil, false)))
+			if got := values["terraform_plan_empty"]; len(got) != 1 || got[0] != tt.want {
+				t.Errorf("terraform_plan_empty = %v, want [%v]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMetricsPlanAge(t *testing.T) {
+	tests := []struct {
+		name, timestamp string
+		// want is the expected age, up to slack seconds more for the test's own run
+		want, slack float64
+	}{
+		{"an hour old", time.Now().Add(-time.Hour).UTC().Format(time.RFC3339), 3600, 5},
+		{"missing timestamp", "", -1, 0},
+		{"unparseable timestamp", "yesterday", -1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			plan := `{"format_version": "1.2", "resource_changes": []}`
+			if tt.timestamp != "" {
+				plan = `{"format_version": "1.2", "timestamp": "` + tt.timestamp + `", "resource_changes": []}`
+			}
+			values := gatherValues(t, buildCollectors(parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", plan)}), newMetricRegistry(nil, false)))
+			got := values["terraform_plan_age_seconds"]
+			if len(got) != 1 || got[0] < tt.want || got[0] > tt.want+tt.slack {
+				t.Errorf("terraform_plan_age_seconds = %v, want [%v]", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package exporter
+
+// Resource types whose replacement typically causes downtime.
+var defaultDowntimeResourceTypes = []string{
+	"aws_db_instance",
+	"aws_rds_cluster",
+	"aws_instance",
+	"aws_elasticache_cluster",
+	"aws_lb",
+	"google_sql_database_instance",
+	"google_compute_instance",
+	"azurerm_linux_virtual_machine",
+	"azurerm_windows_virtual_machine",
+}
+
+// downtimeAddresses returns the addresses of planned replacements of the given
+// resource types.
+func downtimeAddresses(changes []ResourceChange, types []string) []string {
+	var addrs []string
+	for _, rc := range changes {
+		if contains(types, rc.Type) && isReplace(rc.Change.Actions) {
+			addrs = append(addrs, rc.Address)
+		}
+	}
+	return addrs
+}
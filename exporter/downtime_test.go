@@ -0,0 +1,46 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// downtimePlanJSON replaces one listed and one unlisted resource type and updates a
+// listed one in place.
+const downtimePlanJSON = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "aws_db_instance.main", "mode": "managed", "type": "aws_db_instance", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["delete", "create"]}},
+    {"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["delete", "create"]}},
+    {"address": "aws_db_instance.replica", "mode": "managed", "type": "aws_db_instance", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["update"]}}
+  ]
+}`
+
+func TestDowntimeRiskChanges(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	t.Setenv("PUSH_STATE_FILE", "")
+	t.Setenv("DOWNTIME_RESOURCE_TYPES", "aws_db_instance,aws_elasticache_cluster")
+	report := filepath.Join(t.TempDir(), "downtime.txt")
+	t.Setenv("DOWNTIME_REPORT_PATH", report)
+
+	m, err := CollectAndPush(Config{PlanPath: writeTestFile(t, "plan.json", downtimePlanJSON), DryRun: true})
+	if err != nil {
+		t.Fatalf("CollectAndPush: %v", err)
+	}
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+	if got := values["terraform_downtime_risk_changes"]; len(got) != 1 || got[0] != 1 {
+		t.Errorf("terraform_downtime_risk_changes = %v, want [1]", got)
+	}
+	content, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(content), "aws_db_instance.main\n"; got != want {
+		t.Errorf("downtime report = %q, want %q", got, want)
+	}
+}
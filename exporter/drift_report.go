@@ -0,0 +1,58 @@
+package exporter
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// driftedAddresses returns the sorted addresses of the drifted resources in a
+// plan's resource_drift section.
+func driftedAddresses(drift []ResourceChange) []string {
+	var addrs []string
+	for _, rc := range drift {
+		if isDriftChange(rc) && rc.Address != "" {
+			addrs = append(addrs, rc.Address)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// Refresh/plan output lists drifted objects as "# aws_instance.web has changed" or
+// "# aws_instance.web has been deleted".
+var driftLinePattern = regexp.MustCompile(`#\s+(\S+)\s+has (?:changed|been deleted)`)
+
+// driftedAddressesFromLog collects drifted resource addresses from the "Objects
+// have changed outside of Terraform" section of a text refresh or plan log.
+func driftedAddressesFromLog(path string) []string {
+	file, err := openLog(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	seen := map[string]bool{}
+	var addrs []string
+	scanner := newLogScanner(file)
+	for scanner.Scan() {
+		if m := driftLinePattern.FindStringSubmatch(scanner.Text()); m != nil && !seen[m[1]] {
+			seen[m[1]] = true
+			addrs = append(addrs, m[1])
+		}
+	}
+	warnScanErr(scanner, path)
+	sort.Strings(addrs)
+	return addrs
+}
+
+// writeAddressReport writes one resource address per line, as used by the drift and
+// downtime reports; an empty file means no resources were affected.
+func writeAddressReport(path string, addrs []string) error {
+	content := strings.Join(addrs, "\n")
+	if content != "" {
+		content += "\n"
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
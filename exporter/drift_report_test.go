@@ -0,0 +1,91 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// driftPlanJSON returns a plan whose resource_drift section has an update for each
+// address.
+func driftPlanJSON(addrs ...string) string {
+	var drift []string
+	for _, addr := range addrs {
+		drift = append(drift, fmt.Sprintf(`{"address": %q, "mode": "managed", "type": "aws_instance", "change": {"actions": ["update"]}}`, addr))
+	}
+	return fmt.Sprintf(`{"format_version": "1.2", "resource_drift": [%s], "resource_changes": []}`, strings.Join(drift, ","))
+}
+
+func TestDriftResourceCount(t *testing.T) {
+	tests := []struct {
+		name         string
+		addrs        []string
+		wantCount    float64
+		wantDetected float64
+	}{
+		{"no drift", nil, 0, 0},
+		{"one drifted resource", []string{"aws_instance.web"}, 1, 1},
+		{"several drifted resources", []string{"aws_instance.web", "aws_instance.api", "aws_instance.worker"}, 3, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			m := parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", driftPlanJSON(tt.addrs...))})
+			values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+			for name, want := range map[string]float64{
+				"terraform_drift_resource_count": tt.wantCount,
+				"terraform_drift_detected":       tt.wantDetected,
+			} {
+				if got := values[name]; len(got) != 1 || got[0] != want {
+					t.Errorf("%s = %v, want [%v]", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDriftReport(t *testing.T) {
+	tests := []struct {
+		name             string
+		plan, refreshLog string
+		want             string
+	}{
+		{"plan resource_drift", driftPlanJSON("aws_instance.web", "aws_instance.api"), "", "aws_instance.api\naws_instance.web\n"},
+		{"plan without resource_drift", `{"format_version": "1.2", "resource_changes": []}`, "", ""},
+		{
+			"refresh log", "",
+			"Note: Objects have changed outside of Terraform\n\n  # aws_instance.web has changed\n  # aws_s3_bucket.logs has been deleted\n",
+			"aws_instance.web\naws_s3_bucket.logs\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			t.Setenv("PUSH_STATE_FILE", "")
+			report := filepath.Join(t.TempDir(), "drift.txt")
+			t.Setenv("DRIFT_REPORT_PATH", report)
+			cfg := Config{DryRun: true}
+			if tt.plan != "" {
+				cfg.PlanPath = writeTestFile(t, "plan.json", tt.plan)
+			}
+			if tt.refreshLog != "" {
+				cfg.RefreshLogPath = writeTestFile(t, "refresh.log", tt.refreshLog)
+			}
+
+			if _, err := CollectAndPush(cfg); err != nil {
+				t.Fatalf("CollectAndPush: %v", err)
+			}
+			got, err := os.ReadFile(report)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("drift report = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
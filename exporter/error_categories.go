@@ -0,0 +1,39 @@
+package exporter
+
+import "strings"
+
+// errorCategories lists, in priority order, the substrings (lower-case) used to
+// bucket an error block. Anything unmatched is counted as "other".
+var errorCategories = []struct {
+	name     string
+	patterns []string
+}{
+	{"auth", []string{"credential", "unauthorized", "access denied", "accessdenied", "authentication", "forbidden", "invalidclienttokenid", "expiredtoken", "permission denied"}},
+	{"timeout", []string{"timeout", "timed out", "throttl", "rate exceeded", "too many requests"}},
+	{"already_exists", []string{"already exists", "alreadyexists", "entityalreadyexists"}},
+}
+
+// categorizeError buckets an error block by conservative substring matching.
+func categorizeError(block string) string {
+	block = strings.ToLower(block)
+	for _, c := range errorCategories {
+		for _, p := range c.patterns {
+			if strings.Contains(block, p) {
+				return c.name
+			}
+		}
+	}
+	return "other"
+}
+
+// isErrorStart reports whether a lower-cased log line opens an error block: a
+// (possibly boxed) "Error:" diagnostic or an error-level -json message.
+func isErrorStart(line string) bool {
+	return strings.Contains(line, "error:") || strings.Contains(line, `"@level":"error"`)
+}
+
+// isErrorBlockEnd reports whether a line closes a diagnostic block.
+func isErrorBlockEnd(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed == "" || trimmed == "╵"
+}
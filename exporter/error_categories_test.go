@@ -0,0 +1,52 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+const categorizedApplyLog = `aws_instance.web: Creating...
+╷
+│ Error: creating EC2 Instance: operation error EC2: RunInstances, https response error StatusCode: 400, api error Throttling: Rate exceeded
+│
+│   with aws_instance.web,
+╵
+╷
+│ Error: No valid credential sources found
+│
+│ Please see https://registry.terraform.io/providers/hashicorp/aws
+│ for more information about providing credentials.
+╵
+╷
+│ Error: creating IAM Role (ci): EntityAlreadyExists: Role with name ci already exists.
+╵
+╷
+│ Error: Invalid count argument
+╵
+`
+
+func TestErrorCategories(t *testing.T) {
+	scan := scanRunLog(writeTestFile(t, "apply.log", categorizedApplyLog))
+	want := map[string]int{"timeout": 1, "auth": 1, "already_exists": 1, "other": 1}
+	if !reflect.DeepEqual(scan.errorCategories, want) {
+		t.Errorf("error categories = %v, want %v", scan.errorCategories, want)
+	}
+}
+
+func TestCategorizeError(t *testing.T) {
+	tests := []struct {
+		block, want string
+	}{
+		{"error: api error Throttling: Rate exceeded", "timeout"},
+		{"error: waiting for RDS Cluster creation: timeout while waiting for state", "timeout"},
+		{"error: ExpiredToken: The security token included in the request is expired", "auth"},
+		{"error: no valid credential sources found", "auth"},
+		{"error: Resource already exists", "already_exists"},
+		{"error: Unsupported argument", "other"},
+	}
+	for _, tt := range tests {
+		if got := categorizeError(tt.block); got != tt.want {
+			t.Errorf("categorizeError(%q) = %q, want %q", tt.block, got, tt.want)
+		}
+	}
+}
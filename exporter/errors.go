@@ -0,0 +1,24 @@
+package exporter
+
+import "errors"
+
+// Errors returned by CollectAndPush, RunAll and DeleteMetrics wrap one of these, so
+// callers can tell the failures apart with errors.Is.
+var (
+	// ErrMissingConfig means a required setting is missing or invalid, e.g. an
+	// unset PUSHGATEWAY_JOB or an unknown OUTPUT. Nothing was pushed.
+	ErrMissingConfig = errors.New("invalid configuration")
+	// ErrPlanParse means the configured plan JSON was missing or unparseable. The
+	// metrics, with terraform_plan_parse_error set, were still pushed unless the
+	// error also wraps ErrPushFailed.
+	ErrPlanParse = errors.New("parsing Terraform plan failed")
+	// ErrReadTimeout means the plan and logs could not be read within
+	// EXPORTER_TIMEOUT_SECONDS. Nothing was pushed.
+	ErrReadTimeout = errors.New("reading Terraform plan and logs timed out")
+	// ErrPushFailed means writing the metrics to the outputs failed.
+	ErrPushFailed = errors.New("pushing metrics failed")
+	// ErrAlreadyPushed means PUSH_STATE_FILE records the run as pushed, so nothing
+	// was pushed again. It is not a failure, but follow-up steps such as the
+	// summary and the Slack notification should be skipped as well.
+	ErrAlreadyPushed = errors.New("metrics for this run were already pushed")
+)
@@ -0,0 +1,95 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCollectAndPushMissingConfig(t *testing.T) {
+	t.Setenv("PUSHGATEWAY_JOB", "")
+	t.Setenv("PUSHGATEWAY_URL", "")
+
+	_, err := CollectAndPush(Config{Outputs: []string{"pushgateway"}})
+	if !errors.Is(err, ErrMissingConfig) {
+		t.Fatalf("err = %v, want ErrMissingConfig", err)
+	}
+}
+
+func TestCollectAndPushPushFailed(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer gateway.Close()
+	t.Setenv("PUSHGATEWAY_JOB", "terraform")
+	t.Setenv("PUSHGATEWAY_URL", gateway.URL)
+
+	_, err := CollectAndPush(Config{Outputs: []string{"pushgateway"}})
+	if !errors.Is(err, ErrPushFailed) {
+		t.Fatalf("err = %v, want ErrPushFailed", err)
+	}
+	if errors.Is(err, ErrMissingConfig) || errors.Is(err, ErrReadTimeout) || errors.Is(err, ErrPlanParse) {
+		t.Fatalf("err = %v wraps more than ErrPushFailed", err)
+	}
+}
+
+func TestCollectAndPushPlanParse(t *testing.T) {
+	gw := newRecordingPushgateway(0)
+	defer gw.Close()
+	setPushgatewayEnv(t, gw.URL)
+	plan := writeTestFile(t, "plan.json", `{"resource_changes": [`)
+
+	m, err := CollectAndPush(Config{PlanPath: plan, Outputs: []string{"pushgateway"}})
+	if !errors.Is(err, ErrPlanParse) {
+		t.Fatalf("err = %v, want ErrPlanParse", err)
+	}
+	if errors.Is(err, ErrPushFailed) || errors.Is(err, ErrReadTimeout) {
+		t.Fatalf("err = %v wraps more than ErrPlanParse", err)
+	}
+	if !m.PlanParseError {
+		t.Error("Metrics.PlanParseError not set")
+	}
+	// The metrics still reach the Pushgateway
+	if got := gw.Requests(); len(got) == 0 {
+		t.Error("nothing pushed for an unparseable plan")
+	}
+}
+
+func TestCollectAndPushPlanParseAndPushFailed(t *testing.T) {
+	gw := newRecordingPushgateway(http.StatusServiceUnavailable)
+	defer gw.Close()
+	setPushgatewayEnv(t, gw.URL)
+	plan := writeTestFile(t, "plan.json", "")
+
+	_, err := CollectAndPush(Config{PlanPath: plan, Outputs: []string{"pushgateway"}})
+	if !errors.Is(err, ErrPushFailed) || !errors.Is(err, ErrPlanParse) {
+		t.Fatalf("err = %v, want both ErrPushFailed and ErrPlanParse", err)
+	}
+}
+
+func TestCollectAndPushExporterTimeout(t *testing.T) {
+	release := make(chan struct{})
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer gateway.Close()
+	defer close(release)
+	setPushgatewayEnv(t, gateway.URL)
+	t.Setenv("PUSH_TIMEOUT_SECONDS", "60")
+	t.Setenv("EXPORTER_TIMEOUT_SECONDS", "1")
+
+	start := time.Now()
+	_, err := CollectAndPush(Config{Outputs: []string{"pushgateway"}})
+	if !errors.Is(err, ErrPushFailed) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want ErrPushFailed at the deadline", err)
+	}
+	if errors.Is(err, ErrReadTimeout) {
+		t.Errorf("err = %v, the logs were read in time", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 5*time.Second {
+		t.Errorf("push gave up after %s, want about EXPORTER_TIMEOUT_SECONDS=1", elapsed)
+	}
+}
@@ -0,0 +1,33 @@
+//go:build unix
+
+package exporter
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestCollectAndPushReadTimeout(t *testing.T) {
+	// Opening a FIFO without a writer blocks, like a read on a stuck mount.
+	fifo := filepath.Join(t.TempDir(), "plan.json")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	t.Cleanup(func() {
+		if f, err := os.OpenFile(fifo, os.O_WRONLY, 0); err == nil {
+			f.Close()
+		}
+	})
+	t.Setenv("EXPORTER_TIMEOUT_SECONDS", "1")
+
+	_, err := CollectAndPush(Config{PlanPath: fifo, DryRun: true})
+	if !errors.Is(err, ErrReadTimeout) {
+		t.Fatalf("err = %v, want ErrReadTimeout", err)
+	}
+	if errors.Is(err, ErrPlanParse) {
+		t.Fatalf("err = %v, a timeout is not a parse failure", err)
+	}
+}
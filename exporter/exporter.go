@@ -0,0 +1,488 @@
+// Package exporter parses Terraform plans and logs into Prometheus metrics and writes
+// them to a Pushgateway or the other configured outputs. The terraform-prometheus-exporter
+// command is a thin wrapper around it.
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type ResourceChange struct {
+	Address string `json:"address"`
+	// PreviousAddress is set when a moved block or state move changed the address
+	PreviousAddress string `json:"previous_address"`
+	// ModuleAddress is empty for resources in the root module
+	ModuleAddress string `json:"module_address"`
+	// Mode is "managed" for resources and "data" for data sources
+	Mode         string `json:"mode"`
+	Type         string `json:"type"`
+	ActionReason string `json:"action_reason"`
+	Change       struct {
+		Actions      []string    `json:"actions"`
+		Before       interface{} `json:"before"`
+		After        interface{} `json:"after"`
+		AfterUnknown interface{} `json:"after_unknown"`
+		// Sensitive masks mirror before/after with true for sensitive attributes
+		BeforeSensitive interface{} `json:"before_sensitive"`
+		AfterSensitive  interface{} `json:"after_sensitive"`
+	} `json:"change"`
+}
+
+type OutputChange struct {
+	Actions []string `json:"actions"`
+}
+
+type PlanJSON struct {
+	TerraformVersion string                  `json:"terraform_version"`
+	Timestamp        string                  `json:"timestamp"`
+	ResourceChanges  []ResourceChange        `json:"resource_changes"`
+	ResourceDrift    []ResourceChange        `json:"resource_drift"`
+	OutputChanges    map[string]OutputChange `json:"output_changes"`
+}
+
+// parseLogStats reads the apply summary from either human-readable output or
+// `terraform apply -json` output; the format is sniffed from the first non-empty line.
+// When the log holds several apply summaries (multiple modules or retries), the
+// counts of all of them are summed.
+func parseLogStats(path string) (added, changed, destroyed, imported int) {
+	file, err := openLog(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	sniffed, jsonLines := false, false
+	scanner := newLogScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !sniffed {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			sniffed, jsonLines = true, isJSONLogLine(line)
+		}
+		if jsonLines {
+			// {"type":"change_summary","changes":{"add":1,"change":0,"import":0,"remove":0,"operation":"apply"}}
+			msg, ok := parseJSONLogLine(line)
+			if !ok || msg.Type != "change_summary" || msg.Changes == nil || msg.Changes.Operation == "plan" {
+				continue
+			}
+			added += msg.Changes.Add
+			changed += msg.Changes.Change
+			destroyed += msg.Changes.Remove
+			imported += msg.Changes.Import
+			continue
+		}
+		if strings.Contains(line, "Apply complete!") {
+			// Terraform summary: Apply complete! Resources: 1 added, 0 changed, 0 destroyed.
+			fields := strings.Split(line, ":")
+			if len(fields) < 2 {
+				continue
+			}
+			stats := strings.Split(fields[1], ",")
+			for _, stat := range stats {
+				parts := strings.Fields(strings.TrimSuffix(strings.TrimSpace(stat), "."))
+				if len(parts) < 2 {
+					continue
+				}
+				count, _ := strconv.Atoi(parts[0])
+				switch parts[1] {
+				case "added":
+					added += count
+				case "changed":
+					changed += count
+				case "destroyed":
+					destroyed += count
+				case "imported":
+					imported += count
+				}
+			}
+		}
+	}
+	warnScanErr(scanner, path)
+	return
+}
+
+// parsePlanLogStats reads the "Plan:" summary line of a text plan log, e.g.
+// "Plan: 1 to import, 3 to add, 1 to change, 2 to destroy." A "No changes." line
+// yields zero counts. ok is false when neither line is present.
+func parsePlanLogStats(path string) (toAdd, toChange, toDestroy, toImport int, ok bool) {
+	file, err := openLog(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := newLogScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "No changes.") {
+			ok = true
+			continue
+		}
+		if !strings.HasPrefix(line, "Plan:") {
+			continue
+		}
+		ok = true
+		stats := strings.Split(strings.TrimPrefix(line, "Plan:"), ",")
+		for _, stat := range stats {
+			parts := strings.Fields(strings.TrimSuffix(strings.TrimSpace(stat), "."))
+			if len(parts) < 3 || parts[1] != "to" {
+				continue
+			}
+			count, _ := strconv.Atoi(parts[0])
+			switch parts[2] {
+			case "add":
+				toAdd = count
+			case "change":
+				toChange = count
+			case "destroy":
+				toDestroy = count
+			case "import":
+				toImport = count
+			}
+		}
+	}
+	warnScanErr(scanner, path)
+	return
+}
+
+// countDriftedResources counts the plan's resource_drift entries, i.e. resources
+// refresh found changed ("update", "no-op") or removed ("delete") outside Terraform.
+func countDriftedResources(drift []ResourceChange) int {
+	count := 0
+	for _, rc := range drift {
+		if isDriftChange(rc) {
+			count++
+		}
+	}
+	return count
+}
+
+// isDriftChange reports whether a resource_drift entry counts as drift.
+func isDriftChange(rc ResourceChange) bool {
+	actions := rc.Change.Actions
+	return contains(actions, "update") || contains(actions, "no-op") || contains(actions, "delete")
+}
+
+func detectDrift(logPath string) float64 {
+	data, err := readLog(logPath)
+	if err != nil {
+		slog.Warn("reading refresh log failed", "path", logPath, "error", err)
+		return 0
+	}
+	logContent := string(data)
+
+	if strings.Contains(strings.ToLower(logContent), "no changes") {
+		return 0
+	}
+	return 1
+}
+
+// defaultExporterTimeoutSeconds bounds a whole collectMetrics call.
+const defaultExporterTimeoutSeconds = 120
+
+// collectMetrics computes the Terraform metrics for the run described by cfg and
+// writes them, together with any extra collectors, to the configured outputs.
+// It returns the parsed metrics; Succeeded reports whether the Terraform run itself
+// succeeded (terraform_result). The whole call, reading the logs included, is
+// bounded by EXPORTER_TIMEOUT_SECONDS (default 120).
+func collectMetrics(cfg Config, extra []prometheus.Collector) (Metrics, error) {
+	ctx, cancel, timeout := exporterContext()
+	defer cancel()
+
+	m, err := collectMetricsContext(ctx, cfg, extra)
+	return m, deadlineError(err, timeout)
+}
+
+// exporterContext returns a context bounded by EXPORTER_TIMEOUT_SECONDS, and the
+// timeout itself.
+func exporterContext() (context.Context, context.CancelFunc, time.Duration) {
+	timeout := time.Duration(envInt("EXPORTER_TIMEOUT_SECONDS", defaultExporterTimeoutSeconds)) * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	return ctx, cancel, timeout
+}
+
+// deadlineError names EXPORTER_TIMEOUT_SECONDS in err when the deadline was hit.
+func deadlineError(err error, timeout time.Duration) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("exporter did not finish within EXPORTER_TIMEOUT_SECONDS (%s): %w", timeout, err)
+	}
+	return err
+}
+
+func collectMetricsContext(ctx context.Context, cfg Config, extra []prometheus.Collector) (Metrics, error) {
+	logs := cfg.runLogs()
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = outputsFromEnv()
+	}
+	isDryRun := cfg.DryRun || dryRunEnabled()
+	if err := validateEnv(os.Getenv("PUSH_MODE"), outputs, isDryRun); err != nil {
+		return Metrics{}, err
+	}
+
+	// Reading a log on a stuck network mount cannot be interrupted, so stop waiting
+	// for it instead.
+	parsed := make(chan Metrics, 1)
+	go func() { parsed <- parseMetrics(logs) }()
+	var m Metrics
+	select {
+	case m = <-parsed:
+	case <-ctx.Done():
+		return Metrics{}, fmt.Errorf("%w: %w", ErrReadTimeout, ctx.Err())
+	}
+	if path := os.Getenv("DRIFT_REPORT_PATH"); path != "" {
+		if err := writeAddressReport(path, m.DriftedAddresses); err != nil {
+			slog.Warn("could not write drift report", "path", path, "error", err)
+		}
+	}
+	if path := os.Getenv("DOWNTIME_REPORT_PATH"); path != "" {
+		if err := writeAddressReport(path, m.DowntimeAddresses); err != nil {
+			slog.Warn("could not write downtime report", "path", path, "error", err)
+		}
+	}
+
+	// A re-run of the same step must not push again, e.g. resetting the duration.
+	statePath := os.Getenv("PUSH_STATE_FILE")
+	fingerprint, pushed := pushState(logs, isDryRun)
+	if pushed {
+		slog.Info("metrics for this run were already pushed, skipping", "state_file", statePath)
+		return m, ErrAlreadyPushed
+	}
+
+	// An unparseable plan is still pushed, as terraform_plan_parse_error, and then
+	// reported to the caller.
+	var planErr error
+	if m.PlanParseError {
+		planErr = fmt.Errorf("%w: %s", ErrPlanParse, logs.planJSON)
+	}
+
+	metrics := newMetricRegistry(parseClampConfig(os.Getenv("METRIC_CLAMP")), os.Getenv("DUPLICATE_METRIC_POLICY") == "sum")
+	collectors := append(append([]prometheus.Collector{}, extra...), buildCollectors(m, metrics)...)
+	if err := pushMetrics(ctx, outputs, isDryRun, collectors, lastSuccessCollector(m)); err != nil {
+		if errors.Is(err, ErrMissingConfig) {
+			return m, err
+		}
+		return m, errors.Join(fmt.Errorf("%w: %w", ErrPushFailed, err), planErr)
+	}
+	if statePath != "" && !isDryRun {
+		if err := recordPush(statePath, fingerprint); err != nil {
+			slog.Warn("could not record push", "error", err)
+		}
+	}
+	// Only a pushed run becomes the baseline for terraform_provider_upgraded
+	if path := os.Getenv("PROVIDER_VERSIONS_STATE_FILE"); path != "" && m.ProviderVersions != nil && !isDryRun {
+		if err := writeProviderVersions(path, m.ProviderVersions); err != nil {
+			slog.Warn("could not record provider versions", "error", err)
+		}
+	}
+	return m, planErr
+}
+
+// isReplace reports whether the actions are exactly a replacement, in either
+// delete-then-create or create-before-destroy (create-then-delete) order.
+func isReplace(actions []string) bool {
+	return len(actions) == 2 &&
+		((actions[0] == "delete" && actions[1] == "create") ||
+			(actions[0] == "create" && actions[1] == "delete"))
+}
+
+func contains(slice []string, val string) bool {
+	for _, v := range slice {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// dryRunEnabled reports whether DRY_RUN=true was given (the --dry-run flag sets it).
+func dryRunEnabled() bool {
+	return os.Getenv("DRY_RUN") == "true"
+}
+
+// outputsFromEnv returns the configured outputs from OUTPUT, or from its
+// single-valued alias BACKEND, defaulting to the Pushgateway.
+func outputsFromEnv() []string {
+	return envList("OUTPUT", envList("BACKEND", []string{"pushgateway"}))
+}
+
+// envList reads a comma-separated env var, falling back to defaults when unset or empty.
+func envList(name string, defaults []string) []string {
+	var values []string
+	for _, v := range strings.Split(os.Getenv(name), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return defaults
+	}
+	return values
+}
+
+// envInt reads an integer env var, falling back to def when unset or invalid.
+func envInt(name string, def int) int {
+	raw := os.Getenv(name)
+	if raw == "" {
+		return def
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil {
+		slog.Warn("invalid integer setting, using default", "name", name, "value", raw, "default", def)
+		return def
+	}
+	return v
+}
+
+// hasUnknownValues reports whether a plan's after_unknown tree marks any attribute
+// as known only after apply.
+func hasUnknownValues(v interface{}) bool {
+	switch t := v.(type) {
+	case bool:
+		return t
+	case map[string]interface{}:
+		for _, child := range t {
+			if hasUnknownValues(child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, child := range t {
+			if hasUnknownValues(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isTerraformRunSuccessful(logPath string) bool {
+	return scanRunLog(logPath).success
+}
+
+// runLogScan is the outcome of a single pass over a plan or apply log.
+type runLogScan struct {
+	// found is false when the log could not be opened
+	found    bool
+	success  bool
+	warnings int
+	// errorCategories counts error blocks by category (see categorizeError).
+	errorCategories map[string]int
+	// stateLock is set when the run failed to acquire the state lock.
+	stateLock bool
+}
+
+// stateLockErrorLine is the (lower-cased) message of a state lock that could not be
+// acquired, e.g. because another run holds it.
+const stateLockErrorLine = "error acquiring the state lock"
+
+// scanRunLogs is scanRunLog for a path that may be a glob: the matching logs are
+// scanned one by one and the run only succeeded if every one of them did.
+func scanRunLogs(logPath string) runLogScan {
+	paths, err := expandLogPath(logPath)
+	if err != nil {
+		return runLogScan{}
+	}
+	combined := runLogScan{success: true, errorCategories: map[string]int{}}
+	for _, path := range paths {
+		scan := scanRunLog(path)
+		if !scan.found {
+			continue
+		}
+		combined.found = true
+		combined.success = combined.success && scan.success
+		combined.warnings += scan.warnings
+		combined.stateLock = combined.stateLock || scan.stateLock
+		for category, count := range scan.errorCategories {
+			combined.errorCategories[category] += count
+		}
+	}
+	if !combined.found {
+		return runLogScan{}
+	}
+	return combined
+}
+
+func scanRunLog(logPath string) runLogScan {
+	file, err := openLog(logPath)
+	if err != nil {
+		return runLogScan{}
+	}
+	defer file.Close()
+
+	hasError := false
+	hasNoChanges := false
+	stateLock := false
+	warnings := 0
+	categories := map[string]int{}
+
+	var block strings.Builder
+	inBlock := false
+	flush := func() {
+		if inBlock {
+			categories[categorizeError(block.String())]++
+			block.Reset()
+			inBlock = false
+		}
+	}
+
+	scanner := newLogScanner(file)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.ToLower(raw)
+
+		if strings.Contains(line, "error") {
+			hasError = true
+		}
+		if strings.Contains(line, "no changes") {
+			hasNoChanges = true
+		}
+		if strings.Contains(line, stateLockErrorLine) {
+			stateLock = true
+		}
+		if isWarningLine(raw) {
+			warnings++
+		}
+
+		switch {
+		case isErrorStart(line):
+			flush()
+			inBlock = true
+			block.WriteString(line)
+		case inBlock && isErrorBlockEnd(line):
+			flush()
+		case inBlock:
+			block.WriteString("\n" + line)
+		}
+	}
+	warnScanErr(scanner, logPath)
+	flush()
+
+	return runLogScan{
+		found:           true,
+		success:         !hasError || hasNoChanges,
+		warnings:        warnings,
+		errorCategories: categories,
+		stateLock:       stateLock,
+	}
+}
+
+// isWarningLine matches plain "Warning:" lines, the boxed "│ Warning" diagnostics
+// and warn-level -json messages.
+func isWarningLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return strings.HasPrefix(trimmed, "Warning:") ||
+		strings.HasPrefix(trimmed, "│ Warning") ||
+		strings.Contains(line, `"@level":"warn"`)
+}
@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestFile writes content to name inside a fresh temporary directory and
+// returns its path.
+func writeTestFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParsePlanLogStats(t *testing.T) {
+	tests := []struct {
+		name                              string
+		log                               string
+		toAdd, toChange, toDestroy, toImp int
+		ok                                bool
+	}{
+		{
+			name:  "add change destroy",
+			log:   "Terraform will perform the following actions:\n\nPlan: 3 to add, 1 to change, 2 to destroy.\n",
+			toAdd: 3, toChange: 1, toDestroy: 2, ok: true,
+		},
+		{
+			name:  "with imports",
+			log:   "Plan: 1 to import, 3 to add, 0 to change, 0 to destroy.\n",
+			toAdd: 3, toImp: 1, ok: true,
+		},
+		{
+			name:     "indented",
+			log:      "   Plan: 0 to add, 4 to change, 0 to destroy.\n",
+			toChange: 4, ok: true,
+		},
+		{
+			name: "no changes",
+			log:  "No changes. Your infrastructure matches the configuration.\n",
+			ok:   true,
+		},
+		{
+			name: "no summary",
+			log:  "Refreshing state...\nError: Invalid reference\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, "plan.log", tt.log)
+			add, change, destroy, imp, ok := parsePlanLogStats(path)
+			if add != tt.toAdd || change != tt.toChange || destroy != tt.toDestroy || imp != tt.toImp || ok != tt.ok {
+				t.Errorf("parsePlanLogStats = %d, %d, %d, %d, %v; want %d, %d, %d, %d, %v",
+					add, change, destroy, imp, ok, tt.toAdd, tt.toChange, tt.toDestroy, tt.toImp, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParsePlanLogStatsMissingFile(t *testing.T) {
+	if _, _, _, _, ok := parsePlanLogStats(filepath.Join(t.TempDir(), "missing.log")); ok {
+		t.Error("parsePlanLogStats reported a summary for a missing file")
+	}
+}
+
+func TestScanRunLogWarnings(t *testing.T) {
+	tests := []struct {
+		name, log    string
+		wantSuccess  bool
+		wantWarnings int
+	}{
+		{"warnings only", "Warning: Argument is deprecated\n\nApply complete! Resources: 1 added, 0 changed, 0 destroyed.\n", true, 1},
+		{
+			"mixed errors and warnings",
+			"│ Warning: Deprecated attribute\n│\n  Warning: Value for undeclared variable\n\n│ Error: creating S3 Bucket (logs): BucketAlreadyExists\n│\n╵\n",
+			false, 2,
+		},
+		{
+			"json log",
+			`{"@level":"warn","@message":"Warning: Deprecated attribute","type":"diagnostic"}` + "\n" +
+				`{"@level":"error","@message":"Error: Invalid reference","type":"diagnostic"}` + "\n",
+			false, 1,
+		},
+		{"no warnings", "Apply complete! Resources: 0 added, 0 changed, 0 destroyed.\n", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scan := scanRunLog(writeTestFile(t, "apply.log", tt.log))
+			if !scan.found || scan.success != tt.wantSuccess || scan.warnings != tt.wantWarnings {
+				t.Errorf("scanRunLog = found %v, success %v, %d warnings, want success %v, %d warnings",
+					scan.found, scan.success, scan.warnings, tt.wantSuccess, tt.wantWarnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// builtinMetricNames are the metrics built outside the metricRegistry, mostly
+// labelled ones. An extra gauge of the same name would make the push fail, whether
+// or not the built-in is emitted in this run.
+var builtinMetricNames = []string{
+	"terraform_changes_by_action_reason",
+	"terraform_error_category",
+	"terraform_execution_duration",
+	"terraform_execution_duration_bucket",
+	"terraform_execution_duration_count",
+	"terraform_execution_duration_sum",
+	"terraform_exporter_build_info",
+	"terraform_last_success_timestamp",
+	"terraform_plan_apply_delta",
+	"terraform_provider_changes",
+	"terraform_provider_version_info",
+	"terraform_replace_reasons",
+	"terraform_resource_changes",
+	"terraform_run_type",
+	"terraform_security_findings",
+	"terraform_slowest_resource_seconds",
+	"terraform_state_resources",
+	"terraform_version_info",
+}
+
+// isBuiltinMetric reports whether the prefixed name is one of builtinMetricNames.
+func isBuiltinMetric(name string) bool {
+	for _, builtin := range builtinMetricNames {
+		if metricName(builtin) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// extraMetric is one gauge from EXTRA_METRICS_FILE.
+type extraMetric struct {
+	name  string
+	value float64
+}
+
+// parseExtraMetrics reads name=value lines, e.g. "module_version=3". Blank lines and
+// "#" comments are ignored; lines with an invalid metric name or a non-numeric value
+// are skipped with a warning.
+func parseExtraMetrics(path string) ([]extraMetric, error) {
+	lines, err := readPatternFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var extras []extraMetric
+	for _, line := range lines {
+		name, raw, ok := strings.Cut(line, "=")
+		name, raw = strings.TrimSpace(name), strings.TrimSpace(raw)
+		if !ok || !metricNamePattern.MatchString(name) {
+			slog.Warn("ignoring invalid EXTRA_METRICS_FILE line", "line", line)
+			continue
+		}
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			slog.Warn("ignoring invalid EXTRA_METRICS_FILE line", "line", line)
+			continue
+		}
+		extras = append(extras, extraMetric{name: name, value: value})
+	}
+	return extras, nil
+}
@@ -0,0 +1,128 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// gatherValues registers the collectors in a fresh registry and returns the value
+// of every series by metric name, failing the test if gathering fails.
+func gatherValues(t *testing.T, collectors []prometheus.Collector) map[string][]float64 {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	values := map[string][]float64{}
+	for _, mf := range families {
+		for _, metric := range mf.GetMetric() {
+			values[mf.GetName()] = append(values[mf.GetName()], metric.GetGauge().GetValue())
+		}
+	}
+	return values
+}
+
+// gatherLabels is gatherValues for the label sets of every series of the named
+// metric.
+func gatherLabels(t *testing.T, collectors []prometheus.Collector, name string) []map[string]string {
+	t.Helper()
+	reg := prometheus.NewPedanticRegistry()
+	for _, c := range collectors {
+		if err := reg.Register(c); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	var series []map[string]string
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range metric.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			series = append(series, labels)
+		}
+	}
+	return series
+}
+
+func writeExtraMetrics(t *testing.T, content string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "extra.txt")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EXTRA_METRICS_FILE", path)
+}
+
+func TestExtraMetricsSkipBuiltinNames(t *testing.T) {
+	writeExtraMetrics(t, "terraform_run_type=5\nterraform_result=7\nterraform_error_category=2\nmodule_version=3\n")
+	t.Setenv("DUPLICATE_METRIC_POLICY", "sum")
+
+	m := Metrics{RunType: "plan", Succeeded: true, ProviderUpgraded: -1, PlanUnknownFields: -1, ResourcesMoved: -1, DriftResourceCount: -1}
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, true)))
+
+	if got := values["module_version"]; len(got) != 1 || got[0] != 3 {
+		t.Errorf("module_version = %v, want [3]", got)
+	}
+	if got := values["terraform_run_type"]; len(got) != 1 || got[0] != 1 {
+		t.Errorf("terraform_run_type = %v, want the built-in [1]", got)
+	}
+	if got := values["terraform_result"]; len(got) != 1 || got[0] != 1 {
+		t.Errorf("terraform_result = %v, want the built-in [1]", got)
+	}
+	if got := values["terraform_error_category"]; len(got) != 0 {
+		t.Errorf("terraform_error_category = %v, want no series", got)
+	}
+}
+
+func TestExtraMetricsSkipPrefixedBuiltinNames(t *testing.T) {
+	writeExtraMetrics(t, "terraform_run_type=5\ninfra_run_type=6\n")
+	t.Setenv("METRIC_PREFIX", "infra_")
+
+	m := Metrics{RunType: "apply", ProviderUpgraded: -1, PlanUnknownFields: -1, ResourcesMoved: -1, DriftResourceCount: -1}
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+
+	if got := values["infra_run_type"]; len(got) != 1 || got[0] != 1 {
+		t.Errorf("infra_run_type = %v, want the built-in [1]", got)
+	}
+}
+
+func TestParseExtraMetrics(t *testing.T) {
+	tests := []struct {
+		name, content string
+		want          []extraMetric
+	}{
+		{"valid lines", "# pinned versions\nmodule_version=3\n\ncost_center = 1.5\n", []extraMetric{{"module_version", 3}, {"cost_center", 1.5}}},
+		{"invalid name", "module-version=3\n2fast=1\nmodule_version=3\n", []extraMetric{{"module_version", 3}}},
+		{"non-numeric value", "module_version=three\nteam=\ncost_center=2\n", []extraMetric{{"cost_center", 2}}},
+		{"missing separator", "module_version 3\ncost_center=2\n", []extraMetric{{"cost_center", 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseExtraMetrics(writeTestFile(t, "extra.txt", tt.content))
+			if err != nil {
+				t.Fatalf("parseExtraMetrics: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseExtraMetrics = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
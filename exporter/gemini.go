@@ -0,0 +1,89 @@
+//go:build !nogemini
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+const (
+	defaultGeminiModel      = "gemini-2.5-flash"
+	defaultGeminiMaxRetries = 2
+)
+
+// contentGenerator is the part of the genai client the summarizer uses, so tests
+// can inject failures.
+type contentGenerator interface {
+	GenerateContent(ctx context.Context, model string, contents []*genai.Content, config *genai.GenerateContentConfig) (*genai.GenerateContentResponse, error)
+}
+
+// geminiSummarizer summarizes using the Gemini API.
+type geminiSummarizer struct {
+	models contentGenerator
+	model  string
+	tokens int
+	// retries is how many times a rate-limited or 5xx call is retried.
+	retries int
+	sleep   func(time.Duration)
+}
+
+func newGeminiSummarizer(ctx context.Context) (*geminiSummarizer, error) {
+	apiKey := os.Getenv("GOOGLE_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("GOOGLE_API_KEY is not set: %w", errSummarizerUnavailable)
+	}
+
+	client, err := genai.NewClient(ctx, &genai.ClientConfig{
+		APIKey:     apiKey,
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: newHTTPClient(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client: %v", err)
+	}
+	model := os.Getenv("GEMINI_MODEL")
+	if model == "" {
+		model = defaultGeminiModel
+	}
+	return &geminiSummarizer{
+		models:  client.Models,
+		model:   model,
+		retries: envInt("GEMINI_MAX_RETRIES", defaultGeminiMaxRetries),
+		sleep:   time.Sleep,
+	}, nil
+}
+
+func (g *geminiSummarizer) Summarize(ctx context.Context, prompt string) (string, error) {
+	var resp *genai.GenerateContentResponse
+	err := withRetry(g.retries+1, g.sleep, isRetryableGeminiError, func() error {
+		var err error
+		resp, err = g.models.GenerateContent(ctx, g.model, genai.Text(prompt), nil)
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("gemini generate content failed: %w", err)
+	}
+	if resp.UsageMetadata != nil {
+		g.tokens = int(resp.UsageMetadata.TotalTokenCount)
+	}
+	return resp.Text(), nil
+}
+
+func (g *geminiSummarizer) tokensUsed() int { return g.tokens }
+
+// isRetryableGeminiError reports whether a Gemini call was rate limited (429) or hit
+// a transient 5xx. Auth, invalid-argument and other errors are not retried.
+func isRetryableGeminiError(err error) bool {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500
+	}
+	return false
+}
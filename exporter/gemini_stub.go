@@ -0,0 +1,14 @@
+//go:build nogemini
+
+package exporter
+
+import (
+	"context"
+	"fmt"
+)
+
+// newGeminiSummarizer is the stand-in for builds with the nogemini tag, which leave
+// out the genai dependency. Selecting gemini then falls back to the basic summary.
+func newGeminiSummarizer(ctx context.Context) (Summarizer, error) {
+	return nil, fmt.Errorf("gemini support not compiled in: %w", errSummarizerUnavailable)
+}
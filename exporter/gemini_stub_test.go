@@ -0,0 +1,18 @@
+//go:build nogemini
+
+package exporter
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestGeminiUnavailableWithoutGenai(t *testing.T) {
+	t.Setenv("SUMMARY_PROVIDER", "gemini")
+	t.Setenv("GOOGLE_API_KEY", "key")
+
+	if _, err := newSummarizer(context.Background()); !errors.Is(err, errSummarizerUnavailable) {
+		t.Fatalf("err = %v, want errSummarizerUnavailable", err)
+	}
+}
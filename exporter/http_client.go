@@ -0,0 +1,32 @@
+package exporter
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// newHTTPClient returns the client used for all outbound requests. It routes through
+// the proxy from HTTP_PROXY/HTTPS_PROXY (honoring NO_PROXY) and, when timeout is
+// positive, bounds both connecting and the whole request by it.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFromEnvironment()
+	if timeout > 0 {
+		transport.DialContext = (&net.Dialer{Timeout: timeout}).DialContext
+	}
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// proxyFromEnvironment reads the proxy variables when the client is built, unlike
+// http.ProxyFromEnvironment, which keeps the first values it saw for the life of
+// the process.
+func proxyFromEnvironment() func(*http.Request) (*url.URL, error) {
+	proxy := httpproxy.FromEnvironment().ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
@@ -0,0 +1,198 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// proxyRecorder is an HTTP proxy that records the requests routed through it. It
+// answers plain requests itself and refuses CONNECT tunnels.
+type proxyRecorder struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []string
+}
+
+func newProxyRecorder() *proxyRecorder {
+	p := &proxyRecorder{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.requests = append(p.requests, r.Method+" "+r.Host)
+		p.mu.Unlock()
+		if r.Method == http.MethodConnect {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	return p
+}
+
+// Requests returns "METHOD host" for every request the proxy received.
+func (p *proxyRecorder) Requests() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.requests...)
+}
+
+func setProxyEnv(t *testing.T, httpProxy, httpsProxy, noProxy string) {
+	t.Helper()
+	for name, value := range map[string]string{"HTTP_PROXY": httpProxy, "HTTPS_PROXY": httpsProxy, "NO_PROXY": noProxy} {
+		t.Setenv(name, value)
+		t.Setenv(strings.ToLower(name), value)
+	}
+}
+
+func TestPushThroughProxy(t *testing.T) {
+	tests := []struct {
+		name, gateway                  string
+		httpProxy, httpsProxy, noProxy bool
+		wantErr                        bool
+		want                           []string
+	}{
+		{name: "HTTP_PROXY", gateway: "http://pushgateway.example:9091", httpProxy: true,
+			want: []string{"PUT pushgateway.example:9091"}},
+		{name: "HTTPS_PROXY", gateway: "https://pushgateway.example", httpsProxy: true, wantErr: true,
+			want: []string{"CONNECT pushgateway.example:443"}},
+		{name: "NO_PROXY", gateway: "http://pushgateway.invalid:9091", httpProxy: true, noProxy: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxy := newProxyRecorder()
+			defer proxy.Close()
+			var httpProxy, httpsProxy, noProxy string
+			if tt.httpProxy {
+				httpProxy = proxy.URL
+			}
+			if tt.httpsProxy {
+				httpsProxy = proxy.URL
+			}
+			if tt.noProxy {
+				noProxy = "pushgateway.invalid"
+			}
+			setProxyEnv(t, httpProxy, httpsProxy, noProxy)
+			setPushgatewayEnv(t, tt.gateway)
+			t.Setenv("PUSH_TIMEOUT_SECONDS", "2")
+
+			err := newPushgatewaySinks(groupingFromEnv(), nil).Write(context.Background(), []prometheus.Collector{testGauge()})
+			if tt.wantErr && err == nil {
+				t.Error("Write succeeded, want an error")
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("Write: %v", err)
+			}
+			if got := proxy.Requests(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("proxied requests = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTTPClientUsesProxy(t *testing.T) {
+	proxy := newProxyRecorder()
+	defer proxy.Close()
+	setProxyEnv(t, proxy.URL, "", "")
+
+	// The Slack, remote-write and summary provider clients are built the same way
+	resp, err := newHTTPClient(0).Get("http://llm.example/v1/chat/completions")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if got, want := proxy.Requests(), []string{"GET llm.example"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("proxied requests = %v, want %v", got, want)
+	}
+}
+
+// silentListener accepts connections and never answers on them.
+func silentListener(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var conns []net.Conn
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() {
+		ln.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+	return "http://" + ln.Addr().String()
+}
+
+// closedPort returns the address of a port nothing listens on.
+func closedPort(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+	return "http://" + addr
+}
+
+func TestPushTimeout(t *testing.T) {
+	tests := []struct {
+		name    string
+		gateway func(*testing.T) string
+	}{
+		{"non-listening port", closedPort},
+		{"gateway never answers", silentListener},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setProxyEnv(t, "", "", "")
+			setPushgatewayEnv(t, tt.gateway(t))
+			t.Setenv("PUSH_TIMEOUT_SECONDS", "1")
+
+			start := time.Now()
+			err := newPushgatewaySinks(groupingFromEnv(), nil).Write(context.Background(), []prometheus.Collector{testGauge()})
+			if err == nil {
+				t.Fatal("Write succeeded, want an error")
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("push failed after %s, want within PUSH_TIMEOUT_SECONDS=1", elapsed)
+			}
+		})
+	}
+}
+
+func TestDeleteMetricsExporterTimeout(t *testing.T) {
+	setProxyEnv(t, "", "", "")
+	setPushgatewayEnv(t, silentListener(t))
+	t.Setenv("PUSH_TIMEOUT_SECONDS", "60")
+	t.Setenv("EXPORTER_TIMEOUT_SECONDS", "1")
+
+	start := time.Now()
+	err := DeleteMetrics()
+	if !errors.Is(err, ErrPushFailed) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, want ErrPushFailed at the deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("delete gave up after %s, want about EXPORTER_TIMEOUT_SECONDS=1", elapsed)
+	}
+}
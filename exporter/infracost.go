@@ -0,0 +1,40 @@
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// infracostReport is the part of `infracost breakdown|diff --format json` output the
+// exporter reads. Infracost encodes costs as decimal strings.
+type infracostReport struct {
+	DiffTotalMonthlyCost *string `json:"diffTotalMonthlyCost"`
+}
+
+// parseInfracost returns the estimated monthly cost delta from an Infracost JSON
+// report. ok is false when the file does not exist or reports no delta.
+func parseInfracost(path string) (delta float64, ok bool, err error) {
+	data, err := readLog(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	var report infracostReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return 0, false, fmt.Errorf("parsing infracost JSON: %w", err)
+	}
+	if report.DiffTotalMonthlyCost == nil || *report.DiffTotalMonthlyCost == "" {
+		return 0, false, nil
+	}
+	delta, err = strconv.ParseFloat(*report.DiffTotalMonthlyCost, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("parsing diffTotalMonthlyCost: %w", err)
+	}
+	return delta, true, nil
+}
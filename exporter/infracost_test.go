@@ -0,0 +1,65 @@
+package exporter
+
+import "testing"
+
+const infracostJSON = `{
+  "version": "0.2",
+  "currency": "USD",
+  "totalMonthlyCost": "1250.5",
+  "pastTotalMonthlyCost": "1208.25",
+  "diffTotalMonthlyCost": "42.25",
+  "projects": []
+}`
+
+func TestParseInfracost(t *testing.T) {
+	tests := []struct {
+		name, content string
+		want          float64
+		wantOK        bool
+		wantErr       bool
+	}{
+		{"increase", infracostJSON, 42.25, true, false},
+		{"decrease", `{"diffTotalMonthlyCost": "-17.5"}`, -17.5, true, false},
+		{"no delta", `{"totalMonthlyCost": "10"}`, 0, false, false},
+		{"empty delta", `{"diffTotalMonthlyCost": ""}`, 0, false, false},
+		{"not a number", `{"diffTotalMonthlyCost": "lots"}`, 0, false, true},
+		{"invalid JSON", `{"diffTotalMonthlyCost":`, 0, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := parseInfracost(writeTestFile(t, "infracost.json", tt.content))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseInfracost() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("parseInfracost() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestEstimatedCostDeltaMetric(t *testing.T) {
+	tests := []struct {
+		name string
+		path func(*testing.T) string
+		want []float64
+	}{
+		{"sample report", func(t *testing.T) string { return writeTestFile(t, "infracost.json", infracostJSON) }, []float64{42.25}},
+		{"missing report", func(t *testing.T) string { return "/nonexistent/infracost.json" }, nil},
+		{"unset", func(*testing.T) string { return "" }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			t.Setenv("INFRACOST_JSON_PATH", tt.path(t))
+			m := parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", replacePlanJSON)})
+
+			values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+			got := values["terraform_estimated_cost_delta"]
+			if len(got) != len(tt.want) || (len(got) == 1 && got[0] != tt.want[0]) {
+				t.Errorf("terraform_estimated_cost_delta = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package exporter
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// jsonLogMessage is one line of the machine-readable output produced by
+// `terraform plan|apply -json`.
+type jsonLogMessage struct {
+	Level     string `json:"@level"`
+	Message   string `json:"@message"`
+	Timestamp string `json:"@timestamp"`
+	Type      string `json:"type"`
+	// Terraform is the core version, set on "version" messages
+	Terraform string `json:"terraform"`
+	Changes   *struct {
+		Add       int    `json:"add"`
+		Change    int    `json:"change"`
+		Remove    int    `json:"remove"`
+		Import    int    `json:"import"`
+		Operation string `json:"operation"`
+	} `json:"changes"`
+	// Hook is set on per-resource messages such as "apply_complete"
+	Hook *struct {
+		Resource struct {
+			Addr string `json:"addr"`
+		} `json:"resource"`
+		Action         string   `json:"action"`
+		ElapsedSeconds *float64 `json:"elapsed_seconds"`
+	} `json:"hook"`
+}
+
+// timestampLayouts are the timestamp formats seen in Terraform plan JSON and -json
+// logs across versions, tried in order.
+var timestampLayouts = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05",
+}
+
+// parseTimestamp parses s with the first matching layout of timestampLayouts and
+// returns that layout. Timestamps without a zone are taken as UTC.
+func parseTimestamp(s string) (t time.Time, layout string, ok bool) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, layout, true
+		}
+	}
+	return time.Time{}, "", false
+}
+
+// isJSONLogLine reports whether a (non-empty) log line looks like -json output.
+func isJSONLogLine(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "{")
+}
+
+func parseJSONLogLine(line string) (jsonLogMessage, bool) {
+	var msg jsonLogMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return msg, false
+	}
+	return msg, true
+}
+
+// jsonLogDuration returns the time between the first and last timestamped -json
+// messages in the log. It returns -1 when the log is missing or has no timestamps,
+// e.g. because it is plain human-readable output.
+func jsonLogDuration(path string) float64 {
+	file, err := openLog(path)
+	if err != nil {
+		return -1
+	}
+	defer file.Close()
+
+	var first, last time.Time
+	scanner := newLogScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !isJSONLogLine(line) {
+			continue
+		}
+		msg, ok := parseJSONLogLine(line)
+		if !ok || msg.Timestamp == "" {
+			continue
+		}
+		ts, _, ok := parseTimestamp(msg.Timestamp)
+		if !ok {
+			continue
+		}
+		if first.IsZero() {
+			first = ts
+		}
+		last = ts
+	}
+	warnScanErr(scanner, path)
+	if first.IsZero() {
+		return -1
+	}
+	return last.Sub(first).Seconds()
+}
+
+// terraformVersionFromLog returns the Terraform core version from the "version"
+// message of a -json log, or "" when there is none.
+func terraformVersionFromLog(path string) string {
+	file, err := openLog(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := newLogScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !isJSONLogLine(line) {
+			continue
+		}
+		if msg, ok := parseJSONLogLine(line); ok && msg.Type == "version" && msg.Terraform != "" {
+			return msg.Terraform
+		}
+	}
+	warnScanErr(scanner, path)
+	return ""
+}
+
+// slowestResource returns the address and elapsed time of the resource that took
+// longest to apply, from the "apply_complete" messages of a -json apply log.
+// ok is false when no resource has complete timing.
+func slowestResource(path string) (addr string, seconds float64, ok bool) {
+	file, err := openLog(path)
+	if err != nil {
+		return "", 0, false
+	}
+	defer file.Close()
+
+	scanner := newLogScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !isJSONLogLine(line) {
+			continue
+		}
+		msg, parsed := parseJSONLogLine(line)
+		if !parsed || msg.Type != "apply_complete" || msg.Hook == nil || msg.Hook.ElapsedSeconds == nil {
+			continue
+		}
+		if elapsed := *msg.Hook.ElapsedSeconds; !ok || elapsed > seconds {
+			addr, seconds, ok = msg.Hook.Resource.Addr, elapsed, true
+		}
+	}
+	warnScanErr(scanner, path)
+	return addr, seconds, ok
+}
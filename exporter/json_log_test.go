@@ -0,0 +1,118 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// jsonRefreshLog is `terraform plan -refresh-only -json` output spanning 12.5s.
+const jsonRefreshLog = `{"@level":"info","@message":"Terraform 1.9.5","@module":"terraform.ui","@timestamp":"2024-09-01T10:00:00.000000Z","terraform":"1.9.5","type":"version","ui":"1.2"}
+{"@level":"info","@message":"aws_s3_bucket.logs: Refreshing state... [id=logs]","@module":"terraform.ui","@timestamp":"2024-09-01T10:00:02.250000Z","type":"refresh_start"}
+{"@level":"info","@message":"aws_s3_bucket.logs: Refresh complete [id=logs]","@module":"terraform.ui","@timestamp":"2024-09-01T10:00:12.500000Z","type":"refresh_complete"}
+`
+
+const plainRefreshLog = `aws_s3_bucket.logs: Refreshing state... [id=logs]
+
+No changes. Your infrastructure still matches the configuration.
+`
+
+func TestRefreshDuration(t *testing.T) {
+	tests := []struct {
+		name, log string
+		want      float64
+	}{
+		{"json log", jsonRefreshLog, 12.5},
+		{"plain log", plainRefreshLog, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			m := parseMetrics(runLogs{refreshLog: writeTestFile(t, "refresh.log", tt.log)})
+			values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+			if got := values["terraform_refresh_duration_seconds"]; len(got) != 1 || got[0] != tt.want {
+				t.Errorf("terraform_refresh_duration_seconds = %v, want [%v]", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTerraformVersionInfo(t *testing.T) {
+	tests := []struct {
+		name, log string
+		want      string
+	}{
+		{"json log version line", jsonRefreshLog, "1.9.5"},
+		{"TERRAFORM_VERSION fallback", plainRefreshLog, "1.5.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			t.Setenv("TERRAFORM_VERSION", "1.5.7")
+			m := parseMetrics(runLogs{planLog: writeTestFile(t, "plan.log", tt.log)})
+			got := gatherLabels(t, buildCollectors(m, newMetricRegistry(nil, false)), "terraform_version_info")
+			if want := []map[string]string{{"version": tt.want}}; !reflect.DeepEqual(got, want) {
+				t.Errorf("terraform_version_info labels = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// timedApplyLog is a -json apply log of three resources. The database starts last
+// and never completes, so it has no timing.
+const timedApplyLog = `{"@level":"info","@message":"aws_s3_bucket.logs: Creating...","@timestamp":"2024-09-01T10:00:00.000000Z","hook":{"resource":{"addr":"aws_s3_bucket.logs"},"action":"create"},"type":"apply_start"}
+{"@level":"info","@message":"aws_instance.web: Creating...","@timestamp":"2024-09-01T10:00:00.100000Z","hook":{"resource":{"addr":"aws_instance.web"},"action":"create"},"type":"apply_start"}
+{"@level":"info","@message":"aws_s3_bucket.logs: Creation complete after 2s [id=logs]","@timestamp":"2024-09-01T10:00:02.000000Z","hook":{"resource":{"addr":"aws_s3_bucket.logs"},"action":"create","id_key":"id","id_value":"logs","elapsed_seconds":2},"type":"apply_complete"}
+{"@level":"info","@message":"aws_iam_role.ci: Modifications complete after 5s [id=ci]","@timestamp":"2024-09-01T10:00:05.000000Z","hook":{"resource":{"addr":"aws_iam_role.ci"},"action":"update","id_key":"id","id_value":"ci","elapsed_seconds":5},"type":"apply_complete"}
+{"@level":"info","@message":"aws_db_instance.main: Creating...","@timestamp":"2024-09-01T10:00:06.000000Z","hook":{"resource":{"addr":"aws_db_instance.main"},"action":"create"},"type":"apply_start"}
+{"@level":"info","@message":"aws_instance.web: Creation complete after 41s [id=i-0123]","@timestamp":"2024-09-01T10:00:41.000000Z","hook":{"resource":{"addr":"aws_instance.web"},"action":"create","id_key":"id","id_value":"i-0123","elapsed_seconds":41},"type":"apply_complete"}
+{"@level":"info","@message":"Apply complete! Resources: 2 added, 1 changed, 0 destroyed.","@timestamp":"2024-09-01T10:00:41.500000Z","changes":{"add":2,"change":1,"import":0,"remove":0,"operation":"apply"},"type":"change_summary"}
+`
+
+func TestSlowestResource(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	t.Setenv("MAX_LABEL_VALUE_LENGTH", "")
+	m := parseMetrics(runLogs{applyLog: writeTestFile(t, "apply.log", timedApplyLog)})
+	collectors := buildCollectors(m, newMetricRegistry(nil, false))
+
+	if got := gatherValues(t, collectors)["terraform_slowest_resource_seconds"]; !reflect.DeepEqual(got, []float64{41}) {
+		t.Errorf("terraform_slowest_resource_seconds = %v, want [41]", got)
+	}
+	if got, want := gatherLabels(t, collectors, "terraform_slowest_resource_seconds"), []map[string]string{{"address": "aws_instance.web"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("terraform_slowest_resource_seconds labels = %v, want %v", got, want)
+	}
+}
+
+func TestSlowestResourceWithoutTiming(t *testing.T) {
+	if addr, _, ok := slowestResource(writeTestFile(t, "apply.log", replaceApplyLog)); ok {
+		t.Errorf("slowestResource = %q for a plain log, want none", addr)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	want := time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		in, layout string
+		want       time.Time
+	}{
+		{"2024-09-01T10:00:00Z", time.RFC3339, want},
+		{"2024-09-01T12:00:00+02:00", time.RFC3339, want},
+		// time.RFC3339 accepts fractional seconds as well, ahead of time.RFC3339Nano
+		{"2024-09-01T10:00:00.123456Z", time.RFC3339, want.Add(123456 * time.Microsecond)},
+		{"2024-09-01T12:00:00+0200", "2006-01-02T15:04:05Z0700", want},
+		{"2024-09-01 10:00:00Z", "2006-01-02 15:04:05Z07:00", want},
+		{"2024-09-01T10:00:00", "2006-01-02T15:04:05", want},
+	}
+	for _, tt := range tests {
+		got, layout, ok := parseTimestamp(tt.in)
+		if !ok || layout != tt.layout || !got.Equal(tt.want) {
+			t.Errorf("parseTimestamp(%q) = %v, %q, %v; want %v, %q", tt.in, got, layout, ok, tt.want, tt.layout)
+		}
+	}
+	if _, layout, ok := parseTimestamp("01/09/2024 10:00"); ok {
+		t.Errorf("parseTimestamp accepted an invalid timestamp with layout %q", layout)
+	}
+}
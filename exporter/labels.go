@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	defaultMaxLabelValueLength = 256
+	truncationMarker           = "..."
+)
+
+// truncateLabelValue shortens v to at most max bytes, ending with truncationMarker
+// when it had to be cut. Cuts never split a UTF-8 sequence.
+func truncateLabelValue(v string, max int) string {
+	if max <= 0 || len(v) <= max {
+		return v
+	}
+	if max <= len(truncationMarker) {
+		return truncationMarker[:max]
+	}
+	cut := max - len(truncationMarker)
+	for cut > 0 && !utf8.RuneStart(v[cut]) {
+		cut--
+	}
+	return v[:cut] + truncationMarker
+}
+
+// sanitizeLabelValue makes v safe to push: invalid UTF-8 is dropped, newlines and
+// other control characters become spaces, and the result is truncated to max
+// bytes. Slashes are left alone as the push client base64-encodes such values.
+func sanitizeLabelValue(v string, max int) string {
+	v = strings.ToValidUTF8(v, "")
+	v = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return ' '
+		}
+		return r
+	}, v)
+	return truncateLabelValue(strings.TrimSpace(v), max)
+}
+
+// sanitizeLabels applies sanitizeLabelValue to every label value, logging the
+// labels that had to be altered.
+func sanitizeLabels(labels []label, max int) []label {
+	out := make([]label, len(labels))
+	for i, l := range labels {
+		out[i] = label{l.name, sanitizeLabelValue(l.value, max)}
+		if out[i].value != l.value {
+			slog.Warn("grouping label sanitized or truncated", "label", l.name, "value", out[i].value)
+		}
+	}
+	return out
+}
+
+// omitEmptyLabels drops labels without a value; pushing them only creates series
+// with an empty label.
+func omitEmptyLabels(labels []label) []label {
+	var out []label
+	for _, l := range labels {
+		if l.value != "" {
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// collectorLabelNames are the labels the built-in collectors set themselves. A
+// grouping label of the same name would clash with them and fail the push.
+var collectorLabelNames = []string{
+	"action", "address", "build_date", "category", "commit", "le", "provider",
+	"reason", "run_type", "severity", "type", "version",
+}
+
+// parseExtraLabels parses comma-separated key=value pairs such as
+// "env=prod,region=us-east-1". Entries with an invalid or reserved label name, or
+// one that clashes with a built-in grouping or collector label, are skipped with a
+// warning.
+func parseExtraLabels(raw string, builtin []label) []label {
+	var labels []label
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		switch {
+		case !ok || !labelNamePattern.MatchString(name) || strings.HasPrefix(name, "__"):
+			slog.Warn("skipping invalid extra grouping label", "entry", entry)
+			continue
+		case hasLabel(builtin, name) || hasLabel(labels, name) || contains(collectorLabelNames, name):
+			slog.Warn("skipping duplicate extra grouping label", "label", name)
+			continue
+		}
+		labels = append(labels, label{name, strings.TrimSpace(value)})
+	}
+	return labels
+}
+
+func hasLabel(labels []label, name string) bool {
+	for _, l := range labels {
+		if l.name == name {
+			return true
+		}
+	}
+	return false
+}
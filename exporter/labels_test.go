@@ -0,0 +1,182 @@
+package exporter
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestParseExtraLabels(t *testing.T) {
+	builtin := []label{{"instance", "1"}, {"job", "terraform"}}
+	tests := []struct {
+		name string
+		raw  string
+		want []label
+	}{
+		{"valid", "env=prod, region = us-east-1", []label{{"env", "prod"}, {"region", "us-east-1"}}},
+		{"invalid name", "1env=prod,env=prod", []label{{"env", "prod"}}},
+		{"reserved name", "__name__=x,env=prod", []label{{"env", "prod"}}},
+		{"grouping label", "job=other,instance=x,env=prod", []label{{"env", "prod"}}},
+		{"repeated", "env=prod,env=dev", []label{{"env", "prod"}}},
+		{"collector labels", "version=1,type=a,action=b,provider=c,reason=d,category=e,run_type=f,env=prod", []label{{"env", "prod"}}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseExtraLabels(tt.raw, builtin); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseExtraLabels(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExtraLabelsRejectsEveryCollectorLabel(t *testing.T) {
+	for _, name := range collectorLabelNames {
+		if got := parseExtraLabels(name+"=x", nil); len(got) != 0 {
+			t.Errorf("parseExtraLabels accepted collector label %q", name)
+		}
+	}
+}
+
+func TestSanitizeLabelValue(t *testing.T) {
+	tests := []struct {
+		name, in string
+		max      int
+		want     string
+	}{
+		{"slashes kept", "feature/login-page", 200, "feature/login-page"},
+		{"empty", "", 200, ""},
+		{"only whitespace", " \n\t ", 200, ""},
+		{"newlines", "Fix bug\n\nCloses #12", 200, "Fix bug  Closes #12"},
+		{"unicode kept", "Déploiement 🚀 für Prod", 200, "Déploiement 🚀 für Prod"},
+		{"invalid utf-8 dropped", "ok\xff\xfevalue", 200, "okvalue"},
+		{"truncated", "abcdefghij", 8, "abcde..."},
+		{"truncated on a rune boundary", "ééééé", 8, "éé..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeLabelValue(tt.in, tt.max); got != tt.want {
+				t.Errorf("sanitizeLabelValue(%q, %d) = %q, want %q", tt.in, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupingFromEnvSanitizes(t *testing.T) {
+	clearEnv(t)
+	for _, name := range []string{"GIT_BRANCH", "GITHUB_HEAD_REF", "GITHUB_REF_NAME", "PR_NUMBER", "EXTRA_GROUPING_LABELS", "MAX_LABEL_VALUE_LENGTH"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("PUSHGATEWAY_JOB", "terraform/prod")
+	t.Setenv("GITHUB_RUN_ID", "42")
+	t.Setenv("COMMIT_MESSAGE", "Add café\nmenu")
+	t.Setenv("GITHUB_WORKFLOW", "")
+	t.Setenv("GIT_BRANCH", "feature/menu")
+
+	grouping := groupingFromEnv()
+	if grouping.job != "terraform/prod" {
+		t.Errorf("job = %q", grouping.job)
+	}
+	want := []label{
+		{"instance", "42"},
+		{"commit_message", "Add café menu"},
+		{"job", "terraform/prod"},
+		{"branch", "feature/menu"},
+	}
+	if !reflect.DeepEqual(grouping.labels, want) {
+		t.Errorf("labels = %v, want %v", grouping.labels, want)
+	}
+}
+
+func TestInstanceLabelPrecedence(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Skipf("no hostname: %v", err)
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"INSTANCE_LABEL first", map[string]string{"INSTANCE_LABEL": "local", "GITHUB_RUN_ID": "1", "CI_PIPELINE_ID": "2", "BUILD_NUMBER": "3"}, "local"},
+		{"GITHUB_RUN_ID", map[string]string{"GITHUB_RUN_ID": "1", "CI_PIPELINE_ID": "2", "BUILD_NUMBER": "3"}, "1"},
+		{"CI_PIPELINE_ID", map[string]string{"CI_PIPELINE_ID": "2", "BUILD_NUMBER": "3"}, "2"},
+		{"BUILD_NUMBER", map[string]string{"BUILD_NUMBER": "3"}, "3"},
+		{"hostname", nil, hostname},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			if got := instanceLabel(); got != tt.want {
+				t.Errorf("instanceLabel() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobName(t *testing.T) {
+	tests := []struct {
+		name, job string
+		want      string
+		wantErr   bool
+	}{
+		{"plain", "terraform", "terraform", false},
+		{"template", "tf_{{.ENVIRONMENT}}_{{.GITHUB_REPOSITORY}}", "tf_prod_acme/infra", false},
+		{"unset variable", "tf_{{.ENVIRONMENT}}{{.UNSET_VARIABLE}}", "tf_prod", false},
+		{"unparsable template", "tf_{{.ENVIRONMENT", "", true},
+		{"failing template", "tf_{{.ENVIRONMENT.name}}", "", true},
+		{"empty result", "{{.UNSET_VARIABLE}}", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PUSHGATEWAY_JOB", tt.job)
+			t.Setenv("ENVIRONMENT", "prod")
+			t.Setenv("GITHUB_REPOSITORY", "acme/infra")
+			t.Setenv("UNSET_VARIABLE", "")
+			os.Unsetenv("UNSET_VARIABLE")
+
+			got, err := jobName()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("jobName() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("jobName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushEncodesSlashesInGroupingValues(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.EscapedPath()
+	}))
+	defer srv.Close()
+
+	var slept []time.Duration
+	sink := testPushgatewaySink(srv.URL, 1, &slept)
+	sink.grouping = []label{{"branch", "feature/menu"}, {"commit_message", "Add café"}}
+	if err := sink.Write(context.Background(), []prometheus.Collector{testGauge()}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	// The grouping segments come in no particular order
+	for _, want := range []string{
+		"/branch@base64/" + base64.RawURLEncoding.EncodeToString([]byte("feature/menu")),
+		"/commit_message/Add+caf%C3%A9",
+	} {
+		if !strings.HasPrefix(path, "/metrics/job/terraform/") || !strings.Contains(path, want) {
+			t.Errorf("pushed to %s, want a path containing %s", path, want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package exporter
+
+import (
+	"strings"
+)
+
+// Default case-insensitive substrings that indicate cloud API throttling.
+var defaultThrottlePatterns = []string{
+	"throttling",
+	"rate exceeded",
+	"ratelimitexceeded",
+	"requestlimitexceeded",
+	"too many requests",
+	"status code: 429",
+	"statuscode: 429",
+}
+
+// Default status lines Terraform prints while a resource operation is still running.
+var defaultSlowOperationPatterns = []string{
+	"still creating...",
+	"still destroying...",
+	"still modifying...",
+}
+
+// Default anchor phrases for failed custom condition checks.
+var defaultConditionFailurePatterns = []string{
+	"precondition failed",
+	"postcondition failed",
+}
+
+// Lines that only appear when an apply (or destroy) actually ran, in both the
+// human-readable and -json output.
+var applySummaryPatterns = []string{"Apply complete!", "Destroy complete!"}
+
+// countMatchingLines returns how many lines of the file contain at least one of the
+// patterns, compared case-insensitively. A missing file counts as zero matches.
+func countMatchingLines(path string, patterns []string) int {
+	file, err := openLog(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	lowered := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		lowered = append(lowered, strings.ToLower(p))
+	}
+
+	count := 0
+	scanner := newLogScanner(file)
+	for scanner.Scan() {
+		line := strings.ToLower(scanner.Text())
+		for _, p := range lowered {
+			if strings.Contains(line, p) {
+				count++
+				break
+			}
+		}
+	}
+	warnScanErr(scanner, path)
+	return count
+}
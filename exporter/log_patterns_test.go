@@ -0,0 +1,70 @@
+package exporter
+
+import "testing"
+
+const conditionFailureLog = `aws_instance.web: Refreshing state... [id=i-0123456789]
+╷
+│ Error: Resource precondition failed
+│
+│   on main.tf line 12, in resource "aws_instance" "web":
+│   12:       condition     = data.aws_ami.web.architecture == "x86_64"
+│     ├────────────────
+│     │ data.aws_ami.web.architecture is "arm64"
+│
+│ The selected AMI must be for the x86_64 architecture.
+╵
+╷
+│ Error: Resource postcondition failed
+│
+│   on main.tf line 20, in resource "aws_instance" "web":
+╵
+╷
+│ Error: Module output value precondition failed
+╵
+╷
+│ Warning: Check block assertion failed
+│
+│ The health check did not return 200.
+╵
+`
+
+func TestCountConditionFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want int
+	}{
+		{"both kinds", conditionFailureLog, 3},
+		{"precondition", "Error: Resource precondition failed\n", 1},
+		{"postcondition", "│ Error: Resource postcondition failed\n", 1},
+		{"case-insensitive", "ERROR: RESOURCE PRECONDITION FAILED\n", 1},
+		{"no match", "│ Warning: Check block assertion failed\nApply complete! Resources: 1 added, 0 changed, 0 destroyed.\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestFile(t, "apply.log", tt.log)
+			if got := countMatchingLines(path, defaultConditionFailurePatterns); got != tt.want {
+				t.Errorf("countMatchingLines = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConditionFailuresAcrossLogs(t *testing.T) {
+	t.Setenv("CONDITION_FAILURE_PATTERNS", "")
+	logs := runLogs{
+		planLog:  writeTestFile(t, "plan.log", "Error: Resource precondition failed\n"),
+		applyLog: writeTestFile(t, "apply.log", conditionFailureLog),
+	}
+	if got := parseMetrics(logs).ConditionFailures; got != 4 {
+		t.Errorf("ConditionFailures = %d, want 4", got)
+	}
+}
+
+func TestConditionFailuresCustomPatterns(t *testing.T) {
+	t.Setenv("CONDITION_FAILURE_PATTERNS", "check block assertion failed")
+	logs := runLogs{applyLog: writeTestFile(t, "apply.log", conditionFailureLog)}
+	if got := parseMetrics(logs).ConditionFailures; got != 1 {
+		t.Errorf("ConditionFailures = %d, want 1", got)
+	}
+}
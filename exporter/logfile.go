@@ -0,0 +1,155 @@
+package exporter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// defaultMaxLogLineBytes bounds a single log line; -json logs of large plans can
+// exceed bufio.Scanner's 64KB default.
+const defaultMaxLogLineBytes = 10 * 1024 * 1024
+
+// newLogScanner returns a line scanner over r that accepts lines of up to
+// LOG_MAX_LINE_BYTES (default 10MB).
+func newLogScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), envInt("LOG_MAX_LINE_BYTES", defaultMaxLogLineBytes))
+	return scanner
+}
+
+// warnScanErr logs a scan that stopped before the end of path, e.g. on a line
+// longer than LOG_MAX_LINE_BYTES, so partial results do not go unnoticed.
+func warnScanErr(scanner *bufio.Scanner, path string) {
+	if err := scanner.Err(); err != nil {
+		slog.Warn("log was not read completely", "path", path, "error", err)
+	}
+}
+
+// gzipMagic is the two-byte header every gzip stream starts with.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// gzipLogReader closes both the decompressor and the underlying file.
+type gzipLogReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r gzipLogReader) Close() error {
+	r.Reader.Close()
+	return r.file.Close()
+}
+
+type bufferedLogReader struct {
+	*bufio.Reader
+	file *os.File
+}
+
+func (r bufferedLogReader) Close() error { return r.file.Close() }
+
+// multiLogReader reads several logs one after the other and closes all of them.
+type multiLogReader struct {
+	io.Reader
+	logs []io.ReadCloser
+}
+
+func (r multiLogReader) Close() error {
+	for _, l := range r.logs {
+		l.Close()
+	}
+	return nil
+}
+
+// isLogGlob reports whether path is a glob pattern such as "apply-*.log" rather
+// than a single file.
+func isLogGlob(path string) bool {
+	return strings.Contains(path, "*")
+}
+
+// expandLogPath returns the files matching a glob path in sorted order, or path
+// itself when it is not a glob.
+func expandLogPath(path string) ([]string, error) {
+	if !isLogGlob(path) {
+		return []string{path}, nil
+	}
+	matches, err := filepath.Glob(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no files match %s: %w", path, os.ErrNotExist)
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// logExists reports whether path exists or, for a glob, matches at least one file.
+func logExists(path string) bool {
+	paths, err := expandLogPath(path)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(paths[0])
+	return err == nil
+}
+
+// openLog opens a log or plan file for reading. Files ending in ".gz" or starting
+// with the gzip magic number are decompressed transparently. A glob path opens all
+// matching files, read in sorted order as if they were one log.
+func openLog(path string) (io.ReadCloser, error) {
+	if !isLogGlob(path) {
+		return openLogFile(path)
+	}
+	paths, err := expandLogPath(path)
+	if err != nil {
+		return nil, err
+	}
+	var logs []io.ReadCloser
+	var readers []io.Reader
+	for _, p := range paths {
+		l, err := openLogFile(p)
+		if err != nil {
+			multiLogReader{logs: logs}.Close()
+			return nil, err
+		}
+		// Separate the files so a last line without newline is not joined with the next
+		logs = append(logs, l)
+		readers = append(readers, l, strings.NewReader("\n"))
+	}
+	return multiLogReader{io.MultiReader(readers...), logs}, nil
+}
+
+func openLogFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	buffered := bufio.NewReader(file)
+	magic, _ := buffered.Peek(len(gzipMagic))
+	if !strings.HasSuffix(path, ".gz") && string(magic) != string(gzipMagic) {
+		return bufferedLogReader{buffered, file}, nil
+	}
+	gz, err := gzip.NewReader(buffered)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return gzipLogReader{gz, file}, nil
+}
+
+// readLog is os.ReadFile for files that may be gzip-compressed.
+func readLog(path string) ([]byte, error) {
+	r, err := openLog(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
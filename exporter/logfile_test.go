@@ -0,0 +1,139 @@
+package exporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const applyLogFixture = `aws_instance.web: Creating...
+Warning: Argument is deprecated
+aws_instance.web: Creation complete after 32s [id=i-0123456789]
+
+Apply complete! Resources: 1 added, 2 changed, 0 destroyed.
+`
+
+// writeGzip compresses content into name inside dir and returns its path.
+func writeGzip(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadLogGzipRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"apply.log.gz", "apply.log"} {
+		t.Run(name, func(t *testing.T) {
+			// Without a .gz suffix the gzip header alone must be recognised
+			path := writeGzip(t, dir, name, applyLogFixture)
+			data, err := readLog(path)
+			if err != nil {
+				t.Fatalf("readLog: %v", err)
+			}
+			if string(data) != applyLogFixture {
+				t.Errorf("readLog = %q, want the original log", data)
+			}
+		})
+	}
+}
+
+func TestReadLogPlain(t *testing.T) {
+	data, err := readLog(writeTestFile(t, "apply.log", applyLogFixture))
+	if err != nil || string(data) != applyLogFixture {
+		t.Errorf("readLog = %q, %v; want the log unchanged", data, err)
+	}
+}
+
+func TestReadLogCorruptGzip(t *testing.T) {
+	if _, err := readLog(writeTestFile(t, "apply.log.gz", "not gzip at all")); err == nil {
+		t.Error("readLog accepted a .gz file that is not gzip")
+	}
+}
+
+func TestScanGzipLog(t *testing.T) {
+	path := writeGzip(t, t.TempDir(), "apply.log.gz", applyLogFixture)
+
+	if added, changed, destroyed, _ := parseLogStats(path); added != 1 || changed != 2 || destroyed != 0 {
+		t.Errorf("parseLogStats = %d, %d, %d; want 1, 2, 0", added, changed, destroyed)
+	}
+	if got := countMatchingLines(path, []string{"creation complete"}); got != 1 {
+		t.Errorf("countMatchingLines = %d, want 1", got)
+	}
+	scan := scanRunLog(path)
+	if !scan.found || !scan.success || scan.warnings != 1 {
+		t.Errorf("scanRunLog = %+v, want a successful run with one warning", scan)
+	}
+}
+
+func TestReadLogGlobMixesGzipAndPlain(t *testing.T) {
+	dir := t.TempDir()
+	writeGzip(t, dir, "apply-1.log.gz", "Apply complete! Resources: 1 added, 0 changed, 0 destroyed.\n")
+	if err := os.WriteFile(filepath.Join(dir, "apply-2.log"), []byte("Apply complete! Resources: 2 added, 0 changed, 1 destroyed.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if added, _, destroyed, _ := parseLogStats(filepath.Join(dir, "apply-*")); added != 3 || destroyed != 1 {
+		t.Errorf("parseLogStats = %d added, %d destroyed; want 3, 1", added, destroyed)
+	}
+}
+
+func TestParseLogStatsSumsSummaries(t *testing.T) {
+	textLog := `Apply complete! Resources: 2 added, 1 changed, 0 destroyed.
+aws_instance.web: Creating...
+Apply complete! Resources: 1 added, 0 changed, 3 destroyed.
+Apply complete! Resources: 1 imported, 0 added, 2 changed, 0 destroyed.
+`
+	jsonLog := `{"type":"change_summary","changes":{"add":2,"change":0,"import":0,"remove":1,"operation":"plan"}}
+{"type":"change_summary","changes":{"add":2,"change":1,"import":0,"remove":0,"operation":"apply"}}
+{"type":"change_summary","changes":{"add":1,"change":2,"import":1,"remove":3,"operation":"apply"}}
+`
+	tests := []struct {
+		name string
+		path func(*testing.T) string
+		// added, changed, destroyed, imported
+		want [4]int
+	}{
+		{"text", func(t *testing.T) string { return writeTestFile(t, "apply.log", textLog) }, [4]int{3, 3, 3, 1}},
+		{"gzipped text", func(t *testing.T) string { return writeGzip(t, t.TempDir(), "apply.log.gz", textLog) }, [4]int{3, 3, 3, 1}},
+		// The plan summary is not counted
+		{"json", func(t *testing.T) string { return writeTestFile(t, "apply.json", jsonLog) }, [4]int{3, 3, 3, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			added, changed, destroyed, imported := parseLogStats(tt.path(t))
+			if got := [4]int{added, changed, destroyed, imported}; got != tt.want {
+				t.Errorf("parseLogStats = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanLongLogLine(t *testing.T) {
+	t.Setenv("LOG_MAX_LINE_BYTES", "")
+	// A -json diff line well past bufio.Scanner's 64KB default, before the summary
+	long := `{"@level":"info","@message":"` + strings.Repeat("x", 200*1024) + `","type":"planned_change"}` + "\n"
+	path := writeTestFile(t, "apply.log", "Warning: Argument is deprecated\n"+long+
+		"Error: creating EC2 Instance: InvalidAMIID.NotFound\n\nApply complete! Resources: 1 added, 2 changed, 0 destroyed.\n")
+
+	if added, changed, destroyed, _ := parseLogStats(path); added != 1 || changed != 2 || destroyed != 0 {
+		t.Errorf("parseLogStats = %d, %d, %d; want 1, 2, 0 from after the long line", added, changed, destroyed)
+	}
+	scan := scanRunLog(path)
+	if !scan.found || scan.success || scan.warnings != 1 {
+		t.Errorf("scanRunLog = %+v, want the error after the long line to fail the run", scan)
+	}
+}
@@ -0,0 +1,53 @@
+package exporter
+
+import (
+	"regexp"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// descNamePattern extracts the metric name from prometheus.Desc.String(), which is
+// the only place a Desc exposes it.
+var descNamePattern = regexp.MustCompile(`fqName: "([^"]*)"`)
+
+// collectorNames returns the metric names a collector describes.
+func collectorNames(c prometheus.Collector) []string {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(descs)
+		close(descs)
+	}()
+	var names []string
+	for desc := range descs {
+		if m := descNamePattern.FindStringSubmatch(desc.String()); m != nil {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// metricAllowed applies METRICS_INCLUDE and METRICS_EXCLUDE to an exposed metric
+// name. A non-empty include list admits only its names; a name on both lists is kept.
+func metricAllowed(name string, include, exclude []string) bool {
+	if len(include) > 0 {
+		return contains(include, name)
+	}
+	return !contains(exclude, name)
+}
+
+// filterCollectors drops the collectors whose metrics are all filtered out.
+func filterCollectors(collectors []prometheus.Collector, include, exclude []string) []prometheus.Collector {
+	if len(include) == 0 && len(exclude) == 0 {
+		return collectors
+	}
+	var kept []prometheus.Collector
+	for _, c := range collectors {
+		for _, name := range collectorNames(c) {
+			if metricAllowed(name, include, exclude) {
+				kept = append(kept, c)
+				break
+			}
+		}
+	}
+	return kept
+}
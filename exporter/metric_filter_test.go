@@ -0,0 +1,42 @@
+package exporter
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestFilterCollectors(t *testing.T) {
+	tests := []struct {
+		name             string
+		include, exclude []string
+		want             []string
+	}{
+		{"no lists", nil, nil, []string{"terraform_changes", "terraform_result", "terraform_to_add"}},
+		{"include only", []string{"terraform_result", "terraform_changes"}, nil, []string{"terraform_changes", "terraform_result"}},
+		{"exclude only", nil, []string{"terraform_to_add"}, []string{"terraform_changes", "terraform_result"}},
+		{"include wins over exclude", []string{"terraform_result", "terraform_to_add"}, []string{"terraform_to_add"}, []string{"terraform_result", "terraform_to_add"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "terraform_changes", Help: "test"}, []string{"type"})
+			changes.WithLabelValues("aws_s3_bucket").Set(1)
+			collectors := []prometheus.Collector{
+				prometheus.NewGauge(prometheus.GaugeOpts{Name: "terraform_result", Help: "test"}),
+				prometheus.NewGauge(prometheus.GaugeOpts{Name: "terraform_to_add", Help: "test"}),
+				changes,
+			}
+
+			var got []string
+			for name := range gatherValues(t, filterCollectors(collectors, tt.include, tt.exclude)) {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package exporter
+
+import (
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const defaultMetricPrefix = "terraform_"
+
+var metricNamePattern = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// metricName replaces the default "terraform_" prefix of name with METRIC_PREFIX,
+// when set. validateEnv rejects prefixes that would make invalid names.
+func metricName(name string) string {
+	prefix := os.Getenv("METRIC_PREFIX")
+	if prefix == "" {
+		return name
+	}
+	return prefix + strings.TrimPrefix(name, defaultMetricPrefix)
+}
+
+// metricRegistry holds the plain gauges of one run, keyed by name. Adding a name
+// twice never creates a second gauge: the duplicate is either logged and ignored
+// or, with sumDuplicates, added to the existing value.
+type metricRegistry struct {
+	gauges        map[string]prometheus.Gauge
+	values        map[string]float64
+	order         []string
+	clamps        map[string]clampBounds
+	sumDuplicates bool
+}
+
+func newMetricRegistry(clamps map[string]clampBounds, sumDuplicates bool) *metricRegistry {
+	return &metricRegistry{
+		gauges:        map[string]prometheus.Gauge{},
+		values:        map[string]float64{},
+		clamps:        clamps,
+		sumDuplicates: sumDuplicates,
+	}
+}
+
+// Add sets the gauge name to value, applying any configured clamp. The name is
+// prefixed with metricName; clamps are keyed by the prefixed name.
+func (r *metricRegistry) Add(name, help string, value float64) {
+	name = metricName(name)
+	g, exists := r.gauges[name]
+	if exists {
+		if !r.sumDuplicates {
+			slog.Warn("ignoring duplicate metric", "metric", name)
+			return
+		}
+		value += r.values[name]
+	} else {
+		g = prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+		r.gauges[name] = g
+		r.order = append(r.order, name)
+	}
+	r.values[name] = value
+	if c, ok := r.clamps[name]; ok {
+		value = c.apply(value)
+	}
+	g.Set(value)
+}
+
+// Has reports whether a gauge with the already prefixed name was added.
+func (r *metricRegistry) Has(name string) bool {
+	_, ok := r.gauges[name]
+	return ok
+}
+
+// Collectors returns the gauges in the order they were first added.
+func (r *metricRegistry) Collectors() []prometheus.Collector {
+	collectors := make([]prometheus.Collector, 0, len(r.order))
+	for _, name := range r.order {
+		collectors = append(collectors, r.gauges[name])
+	}
+	return collectors
+}
+
+// clampBounds holds optional lower/upper limits for a single metric.
+type clampBounds struct {
+	min, max       float64
+	hasMin, hasMax bool
+}
+
+func (c clampBounds) apply(v float64) float64 {
+	if c.hasMin && v < c.min {
+		return c.min
+	}
+	if c.hasMax && v > c.max {
+		return c.max
+	}
+	return v
+}
+
+// parseClampConfig parses METRIC_CLAMP, e.g. "terraform_unknown_ratio=0:1,terraform_plan_age_seconds=:86400".
+// Either bound may be left empty to leave that side unbounded. Invalid entries are skipped.
+func parseClampConfig(raw string) map[string]clampBounds {
+	clamps := map[string]clampBounds{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, bounds, ok := strings.Cut(entry, "=")
+		lo, hi, ok2 := strings.Cut(bounds, ":")
+		if !ok || !ok2 || strings.TrimSpace(name) == "" {
+			slog.Warn("ignoring invalid METRIC_CLAMP entry", "entry", entry)
+			continue
+		}
+		var c clampBounds
+		var err error
+		if lo = strings.TrimSpace(lo); lo != "" {
+			if c.min, err = strconv.ParseFloat(lo, 64); err != nil {
+				slog.Warn("ignoring invalid METRIC_CLAMP entry", "entry", entry)
+				continue
+			}
+			c.hasMin = true
+		}
+		if hi = strings.TrimSpace(hi); hi != "" {
+			if c.max, err = strconv.ParseFloat(hi, 64); err != nil {
+				slog.Warn("ignoring invalid METRIC_CLAMP entry", "entry", entry)
+				continue
+			}
+			c.hasMax = true
+		}
+		clamps[strings.TrimSpace(name)] = c
+	}
+	return clamps
+}
@@ -0,0 +1,132 @@
+package exporter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMetricName(t *testing.T) {
+	tests := []struct {
+		prefix, name, want string
+	}{
+		{"", "terraform_result", "terraform_result"},
+		{"infra_", "terraform_result", "infra_result"},
+		{"infra:", "terraform_result", "infra:result"},
+		{"infra_", "module_version", "infra_module_version"},
+	}
+	for _, tt := range tests {
+		t.Setenv("METRIC_PREFIX", tt.prefix)
+		if got := metricName(tt.name); got != tt.want {
+			t.Errorf("METRIC_PREFIX=%q: metricName(%q) = %q, want %q", tt.prefix, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateEnvMetricPrefix(t *testing.T) {
+	tests := []struct {
+		prefix string
+		valid  bool
+	}{
+		{"", true},
+		{"infra_", true},
+		{"team:tf_", true},
+		{"_", true},
+		{"9infra_", false},
+		{"infra-", false},
+		{"infra tf_", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("METRIC_PREFIX", tt.prefix)
+			err := validateEnv("push", []string{"textfile"}, false)
+			if tt.valid && err != nil {
+				t.Errorf("validateEnv: %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrMissingConfig) {
+				t.Errorf("err = %v, want ErrMissingConfig", err)
+			}
+		})
+	}
+}
+
+func TestMetricPrefixAppliesToEveryMetric(t *testing.T) {
+	writeExtraMetrics(t, "terraform_module_version=3\nfeature_flags=12\n")
+	t.Setenv("METRIC_PREFIX", "infra_")
+	t.Setenv("METRIC_CLAMP", "")
+
+	m := Metrics{RunType: "plan", Succeeded: true, ProviderUpgraded: -1, PlanUnknownFields: -1, ResourcesMoved: -1, DriftResourceCount: -1}
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+
+	for name, want := range map[string]float64{
+		"infra_result":         1,
+		"infra_run_type":       1,
+		"infra_module_version": 3,
+		"infra_feature_flags":  12,
+	} {
+		if got := values[name]; len(got) != 1 || got[0] != want {
+			t.Errorf("%s = %v, want [%v]", name, got, want)
+		}
+	}
+	for name := range values {
+		if len(name) >= len(defaultMetricPrefix) && name[:len(defaultMetricPrefix)] == defaultMetricPrefix {
+			t.Errorf("%s kept the default prefix", name)
+		}
+	}
+}
+
+func TestMetricClampUsesPrefixedName(t *testing.T) {
+	t.Setenv("METRIC_PREFIX", "infra_")
+	r := newMetricRegistry(parseClampConfig("infra_plan_age_seconds=:60"), false)
+	r.Add("terraform_plan_age_seconds", "test", 3600)
+
+	values := gatherValues(t, r.Collectors())
+	if got := values["infra_plan_age_seconds"]; len(got) != 1 || got[0] != 60 {
+		t.Errorf("infra_plan_age_seconds = %v, want the clamped [60]", got)
+	}
+}
+
+func TestMetricRegistryDuplicates(t *testing.T) {
+	tests := []struct {
+		policy string
+		sum    bool
+		want   float64
+	}{
+		{"ignore", false, 3},
+		{"sum", true, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			t.Setenv("METRIC_PREFIX", "")
+			r := newMetricRegistry(nil, tt.sum)
+			r.Add("terraform_to_add", "Resources planned to be added", 3)
+			r.Add("terraform_to_add", "Resources planned to be added", 4)
+			r.Add("terraform_to_change", "Resources planned to be changed", 1)
+
+			collectors := r.Collectors()
+			if len(collectors) != 2 {
+				t.Fatalf("got %d collectors, want one per name", len(collectors))
+			}
+			values := gatherValues(t, collectors)
+			if got := values["terraform_to_add"]; len(got) != 1 || got[0] != tt.want {
+				t.Errorf("terraform_to_add = %v, want [%v]", got, tt.want)
+			}
+			if got := values["terraform_to_change"]; len(got) != 1 || got[0] != 1 {
+				t.Errorf("terraform_to_change = %v, want [1]", got)
+			}
+		})
+	}
+}
+
+func TestMetricRegistrySumIsClampedOnce(t *testing.T) {
+	t.Setenv("METRIC_PREFIX", "")
+	r := newMetricRegistry(parseClampConfig("terraform_to_add=:5"), true)
+	r.Add("terraform_to_add", "Resources planned to be added", 4)
+	r.Add("terraform_to_add", "Resources planned to be added", 4)
+	r.Add("terraform_to_add", "Resources planned to be added", -6)
+
+	// The running total is 2; clamping each step would have given 5-6=-1
+	if got := gatherValues(t, r.Collectors())["terraform_to_add"]; len(got) != 1 || got[0] != 2 {
+		t.Errorf("terraform_to_add = %v, want the clamped sum [2]", got)
+	}
+}
@@ -0,0 +1,13 @@
+package exporter
+
+// countMovedResources counts resource changes whose address differs from their
+// previous_address, i.e. resources relocated by a moved block or state move.
+func countMovedResources(changes []ResourceChange) int {
+	count := 0
+	for _, rc := range changes {
+		if rc.PreviousAddress != "" && rc.PreviousAddress != rc.Address {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,56 @@
+package exporter
+
+import "testing"
+
+const movedPlanJSON = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "aws_s3_bucket.logs", "previous_address": "aws_s3_bucket.log", "mode": "managed", "type": "aws_s3_bucket",
+     "change": {"actions": ["no-op"]}},
+    {"address": "module.web.aws_instance.this", "previous_address": "aws_instance.web", "mode": "managed", "type": "aws_instance",
+     "change": {"actions": ["update"]}},
+    {"address": "aws_iam_role.ci", "mode": "managed", "type": "aws_iam_role",
+     "change": {"actions": ["no-op"]}}
+  ]
+}`
+
+const movedPlanLog = `  # aws_s3_bucket.log has moved to aws_s3_bucket.logs
+    resource "aws_s3_bucket" "logs" {
+        id = "logs"
+    }
+
+  # aws_instance.web has moved to module.web.aws_instance.this
+    resource "aws_instance" "this" {
+      ~ instance_type = "t3.small" -> "t3.medium"
+    }
+
+Plan: 0 to add, 1 to change, 0 to destroy.
+`
+
+func TestResourcesMoved(t *testing.T) {
+	tests := []struct {
+		name string
+		logs func(t *testing.T) runLogs
+		want float64
+	}{
+		{"plan JSON", func(t *testing.T) runLogs {
+			return runLogs{planJSON: writeTestFile(t, "plan.json", movedPlanJSON)}
+		}, 2},
+		{"plan log", func(t *testing.T) runLogs {
+			return runLogs{planLog: writeTestFile(t, "plan.log", movedPlanLog)}
+		}, 2},
+		{"plan log without moves", func(t *testing.T) runLogs {
+			return runLogs{planLog: writeTestFile(t, "plan.log", "Plan: 1 to add, 0 to change, 0 to destroy.\n")}
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			values := gatherValues(t, buildCollectors(parseMetrics(tt.logs(t)), newMetricRegistry(nil, false)))
+			if got := values["terraform_resources_moved"]; len(got) != 1 || got[0] != tt.want {
+				t.Errorf("terraform_resources_moved = %v, want [%v]", got, tt.want)
+			}
+		})
+	}
+}
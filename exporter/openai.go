@@ -0,0 +1,99 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const defaultOpenAIModel = "gpt-4o-mini"
+
+// openAISummarizer summarizes using an OpenAI-compatible chat-completions endpoint.
+type openAISummarizer struct {
+	baseURL string
+	apiKey  string
+	model   string
+	client  *http.Client
+	tokens  int
+}
+
+func newOpenAISummarizer() (*openAISummarizer, error) {
+	baseURL := os.Getenv("LLM_BASE_URL")
+	if baseURL == "" {
+		return nil, fmt.Errorf("LLM_BASE_URL is not set: %w", errSummarizerUnavailable)
+	}
+	model := os.Getenv("LLM_MODEL")
+	if model == "" {
+		model = defaultOpenAIModel
+	}
+	return &openAISummarizer{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  os.Getenv("LLM_API_KEY"),
+		model:   model,
+		client:  newHTTPClient(0),
+	}, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+func (o *openAISummarizer) Summarize(ctx context.Context, prompt string) (string, error) {
+	body, err := json.Marshal(chatCompletionRequest{
+		Model:    o.model,
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("chat completion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("chat completion returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var completion chatCompletionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+		return "", fmt.Errorf("decoding chat completion: %w", err)
+	}
+	o.tokens = completion.Usage.TotalTokens
+	if len(completion.Choices) == 0 {
+		return "", nil
+	}
+	return completion.Choices[0].Message.Content, nil
+}
+
+func (o *openAISummarizer) tokensUsed() int { return o.tokens }
@@ -0,0 +1,52 @@
+package exporter
+
+import "strings"
+
+// Text lines Terraform prints as each resource operation finishes.
+const (
+	creationCompleteLine     = "Creation complete after"
+	modificationCompleteLine = "Modifications complete after"
+	destructionCompleteLine  = "Destruction complete after"
+)
+
+// completedOperations counts the resource operations that finished in an apply log,
+// from "apply_complete" messages of -json logs or the "... complete after" lines of
+// human-readable ones. Unlike parseLogStats it does not need the final summary, so
+// it also covers applies that failed midway.
+func completedOperations(path string) (added, changed, destroyed int) {
+	file, err := openLog(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := newLogScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isJSONLogLine(line) {
+			msg, ok := parseJSONLogLine(line)
+			if !ok || msg.Type != "apply_complete" || msg.Hook == nil {
+				continue
+			}
+			switch msg.Hook.Action {
+			case "create":
+				added++
+			case "update":
+				changed++
+			case "delete":
+				destroyed++
+			}
+			continue
+		}
+		switch {
+		case strings.Contains(line, creationCompleteLine):
+			added++
+		case strings.Contains(line, modificationCompleteLine):
+			changed++
+		case strings.Contains(line, destructionCompleteLine):
+			destroyed++
+		}
+	}
+	warnScanErr(scanner, path)
+	return
+}
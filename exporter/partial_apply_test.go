@@ -0,0 +1,58 @@
+package exporter
+
+import "testing"
+
+// partialApplyJSONLog is `terraform apply -json` output that creates two resources
+// and fails on the third.
+const partialApplyJSONLog = `{"@level":"info","@message":"Terraform 1.9.5","@timestamp":"2024-09-01T10:00:00.000000Z","terraform":"1.9.5","type":"version","ui":"1.2"}
+{"@level":"info","@message":"aws_s3_bucket.logs: Creating...","@timestamp":"2024-09-01T10:00:01.000000Z","hook":{"resource":{"addr":"aws_s3_bucket.logs"},"action":"create"},"type":"apply_start"}
+{"@level":"info","@message":"aws_s3_bucket.logs: Creation complete after 2s [id=logs]","@timestamp":"2024-09-01T10:00:03.000000Z","hook":{"resource":{"addr":"aws_s3_bucket.logs"},"action":"create","id_key":"id","id_value":"logs","elapsed_seconds":2},"type":"apply_complete"}
+{"@level":"info","@message":"aws_iam_role.ci: Creating...","@timestamp":"2024-09-01T10:00:03.000000Z","hook":{"resource":{"addr":"aws_iam_role.ci"},"action":"create"},"type":"apply_start"}
+{"@level":"info","@message":"aws_iam_role.ci: Creation complete after 1s [id=ci]","@timestamp":"2024-09-01T10:00:04.000000Z","hook":{"resource":{"addr":"aws_iam_role.ci"},"action":"create","id_key":"id","id_value":"ci","elapsed_seconds":1},"type":"apply_complete"}
+{"@level":"info","@message":"aws_instance.web: Creating...","@timestamp":"2024-09-01T10:00:04.000000Z","hook":{"resource":{"addr":"aws_instance.web"},"action":"create"},"type":"apply_start"}
+{"@level":"error","@message":"Error: creating EC2 Instance: InvalidAMIID.NotFound","@timestamp":"2024-09-01T10:00:05.000000Z","diagnostic":{"severity":"error","summary":"creating EC2 Instance: InvalidAMIID.NotFound","detail":""},"type":"diagnostic"}
+`
+
+func TestCompletedOperations(t *testing.T) {
+	added, changed, destroyed := completedOperations(writeTestFile(t, "apply.log", partialApplyJSONLog))
+	if added != 2 || changed != 0 || destroyed != 0 {
+		t.Errorf("completedOperations = %d, %d, %d; want 2, 0, 0", added, changed, destroyed)
+	}
+}
+
+func TestParseMetricsPartialApply(t *testing.T) {
+	tests := []struct {
+		name, applyLog string
+		want           map[string]float64
+	}{
+		{"two applied, then an error", partialApplyJSONLog, map[string]float64{
+			"terraform_partial_apply": 1,
+			"terraform_added":         2,
+			"terraform_apply_result":  0,
+			"terraform_result":        0,
+		}},
+		{"nothing applied before the error", failedApplyLog, map[string]float64{
+			"terraform_partial_apply": 0,
+			"terraform_added":         0,
+			"terraform_apply_result":  0,
+		}},
+		{"complete apply", replaceApplyLog, map[string]float64{
+			"terraform_partial_apply": 0,
+			"terraform_added":         4,
+			"terraform_apply_result":  1,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			m := parseMetrics(runLogs{applyLog: writeTestFile(t, "apply.log", tt.applyLog)})
+			values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+			for name, want := range tt.want {
+				if got := values[name]; len(got) != 1 || got[0] != want {
+					t.Errorf("%s = %v, want [%v]", name, got, want)
+				}
+			}
+		})
+	}
+}
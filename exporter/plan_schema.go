@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"encoding/json"
+	"log/slog"
+	"sort"
+)
+
+// planSchema lists the documented fields of one object in the `terraform show -json`
+// plan format. A nil *planSchema accepts anything, e.g. attribute values or the
+// configuration, whose contents depend on the providers rather than the format.
+type planSchema struct {
+	fields map[string]*planSchema
+	// values, when set, describes every value of an object keyed by user-chosen
+	// names, such as output_changes.
+	values *planSchema
+}
+
+// changeSchema is the change representation shared by resource and output changes.
+var changeSchema = &planSchema{fields: map[string]*planSchema{
+	"actions":          nil,
+	"before":           nil,
+	"after":            nil,
+	"after_unknown":    nil,
+	"before_sensitive": nil,
+	"after_sensitive":  nil,
+	"replace_paths":    nil,
+	"importing":        nil,
+	"generated_config": nil,
+}}
+
+var resourceChangeSchema = &planSchema{fields: map[string]*planSchema{
+	"address":          nil,
+	"previous_address": nil,
+	"module_address":   nil,
+	"mode":             nil,
+	"type":             nil,
+	"name":             nil,
+	"index":            nil,
+	"deposed":          nil,
+	"provider_name":    nil,
+	"action_reason":    nil,
+	"change":           changeSchema,
+}}
+
+// planFormatSchema is the documented top level of the plan format, descending into
+// the change sections the exporter reads.
+var planFormatSchema = &planSchema{fields: map[string]*planSchema{
+	"format_version":      nil,
+	"terraform_version":   nil,
+	"variables":           nil,
+	"planned_values":      nil,
+	"resource_drift":      resourceChangeSchema,
+	"resource_changes":    resourceChangeSchema,
+	"deferred_changes":    nil,
+	"relevant_attributes": nil,
+	"output_changes":      {values: changeSchema},
+	"prior_state":         nil,
+	"configuration":       nil,
+	"checks":              nil,
+	"timestamp":           nil,
+	"applyable":           nil,
+	"complete":            nil,
+	"errored":             nil,
+}}
+
+// countUnknownPlanFields runs a side pass over the plan JSON and returns the number
+// of distinct field paths (e.g. "resource_changes[].change.foo") that the documented
+// plan format does not list, so a stock plan scores 0. The main decode stays
+// lenient; this only flags schema drift.
+func countUnknownPlanFields(data []byte) (int, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return 0, err
+	}
+	unknown := map[string]bool{}
+	collectUnknownFields(doc, planFormatSchema, "", unknown)
+	if len(unknown) > 0 {
+		paths := make([]string, 0, len(unknown))
+		for path := range unknown {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		slog.Debug("plan JSON has undocumented fields", "fields", paths)
+	}
+	return len(unknown), nil
+}
+
+func collectUnknownFields(v interface{}, schema *planSchema, path string, unknown map[string]bool) {
+	if schema == nil {
+		return
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			collectUnknownFields(item, schema, path+"[]", unknown)
+		}
+	case map[string]interface{}:
+		for key, child := range v {
+			if schema.values != nil {
+				// User-chosen keys collapse into one path, e.g. output_changes.*.actions
+				collectUnknownFields(child, schema.values, joinFieldPath(path, "*"), unknown)
+				continue
+			}
+			childPath := joinFieldPath(path, key)
+			childSchema, ok := schema.fields[key]
+			if !ok {
+				unknown[childPath] = true
+				continue
+			}
+			collectUnknownFields(child, childSchema, childPath, unknown)
+		}
+	}
+}
+
+func joinFieldPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
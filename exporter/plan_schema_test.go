@@ -0,0 +1,60 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+)
+
+// stockPlanJSON is trimmed `terraform show -json` output from Terraform 1.9.
+const stockPlanJSON = `{
+  "format_version": "1.2",
+  "terraform_version": "1.9.5",
+  "variables": {"region": {"value": "eu-west-1"}},
+  "planned_values": {"root_module": {"resources": [{"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "name": "logs", "provider_name": "registry.terraform.io/hashicorp/aws", "schema_version": 0, "values": {"bucket": "logs"}, "sensitive_values": {}}]}},
+  "resource_drift": [
+    {"address": "aws_iam_role.ci", "mode": "managed", "type": "aws_iam_role", "name": "ci", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["update"], "before": {"name": "ci"}, "after": {"name": "ci-2"}, "after_unknown": {}, "before_sensitive": {}, "after_sensitive": {}}}
+  ],
+  "resource_changes": [
+    {"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "name": "logs", "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["create"], "before": null, "after": {"bucket": "logs"}, "after_unknown": {"id": true}, "before_sensitive": false, "after_sensitive": {}}},
+    {"address": "module.web.aws_instance.this[0]", "module_address": "module.web", "mode": "managed", "type": "aws_instance", "name": "this", "index": 0, "provider_name": "registry.terraform.io/hashicorp/aws",
+     "change": {"actions": ["delete", "create"], "before": {"ami": "ami-1"}, "after": {"ami": "ami-2"}, "after_unknown": {}, "before_sensitive": {}, "after_sensitive": {}, "replace_paths": [["ami"]]},
+     "action_reason": "replace_because_cannot_update"}
+  ],
+  "output_changes": {
+    "bucket": {"actions": ["create"], "before": null, "after": "logs", "after_unknown": false, "before_sensitive": false, "after_sensitive": false}
+  },
+  "prior_state": {"format_version": "1.0", "terraform_version": "1.9.5", "values": {"root_module": {}}},
+  "configuration": {"provider_config": {"aws": {"name": "aws", "full_name": "registry.terraform.io/hashicorp/aws"}}, "root_module": {}},
+  "relevant_attributes": [{"resource": "aws_iam_role.ci", "attribute": ["name"]}],
+  "checks": [],
+  "timestamp": "2024-09-01T10:00:00Z",
+  "applyable": true,
+  "complete": true,
+  "errored": false
+}`
+
+func TestCountUnknownPlanFields(t *testing.T) {
+	tests := []struct {
+		name string
+		plan string
+		want int
+	}{
+		{"stock plan", stockPlanJSON, 0},
+		{"unknown top-level field", strings.Replace(stockPlanJSON, `"applyable": true`, `"applyable": true, "plan_stats": {}`, 1), 1},
+		{"unknown change field", strings.Replace(stockPlanJSON, `"replace_paths": [["ami"]]`, `"replace_paths": [["ami"]], "replace_order": "cbd"`, 1), 1},
+		{"unknown output change field", strings.Replace(stockPlanJSON, `"after": "logs",`, `"after": "logs", "ephemeral": false,`, 1), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := countUnknownPlanFields([]byte(tt.plan))
+			if err != nil {
+				t.Fatalf("countUnknownPlanFields: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("countUnknownPlanFields = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
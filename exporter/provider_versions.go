@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	lockProviderPattern = regexp.MustCompile(`^provider\s+"([^"]+)"\s*\{`)
+	lockVersionPattern  = regexp.MustCompile(`^version\s*=\s*"([^"]+)"`)
+)
+
+// parseLockFile returns the selected version of every provider in a
+// .terraform.lock.hcl file, keyed by provider source address such as
+// "registry.terraform.io/hashicorp/aws".
+func parseLockFile(path string) (map[string]string, error) {
+	data, err := readLog(path)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := map[string]string{}
+	provider := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if m := lockProviderPattern.FindStringSubmatch(line); m != nil {
+			provider = m[1]
+			continue
+		}
+		if m := lockVersionPattern.FindStringSubmatch(line); m != nil && provider != "" {
+			versions[provider] = m[1]
+			provider = ""
+		}
+	}
+	return versions, nil
+}
+
+// readProviderVersions reads the versions recorded by a previous run. ok is false
+// when there is no previous run yet.
+func readProviderVersions(path string) (versions map[string]string, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, false, fmt.Errorf("parsing provider versions state: %w", err)
+	}
+	return versions, true, nil
+}
+
+// writeProviderVersions records versions for the next run to compare against.
+func writeProviderVersions(path string, versions map[string]string) error {
+	data, err := json.MarshalIndent(versions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("writing provider versions state: %w", err)
+	}
+	return nil
+}
+
+// providersUpgraded reports whether any provider in current has a different version
+// than in previous. Providers that are new or were removed do not count.
+func providersUpgraded(previous, current map[string]string) bool {
+	for provider, version := range current {
+		if old, ok := previous[provider]; ok && old != version {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+// lockFileHCL is a .terraform.lock.hcl as written by `terraform init`.
+const lockFileHCL = `# This file is maintained automatically by "terraform init".
+# Manual edits may be lost in future updates.
+
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "5.67.0"
+  constraints = "~> 5.0"
+  hashes = [
+    "h1:8wkuQvQiqjjm2+gQepy6xFBfimGoesKz1BPcVKWvED8=",
+    "zh:1259c8106c0a3fc0ed3b3eb814ab88d6a672e678b533f47d1bbbe3107949f43e",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/random" {
+  version = "3.6.3"
+  hashes = [
+    "h1:zG9uFP8l9u+yGZZvi5Te7PV62j50azpgwPunq2vTm1E=",
+  ]
+}
+`
+
+func TestParseLockFile(t *testing.T) {
+	got, err := parseLockFile(writeTestFile(t, ".terraform.lock.hcl", lockFileHCL))
+	if err != nil {
+		t.Fatalf("parseLockFile: %v", err)
+	}
+	want := map[string]string{
+		"registry.terraform.io/hashicorp/aws":    "5.67.0",
+		"registry.terraform.io/hashicorp/random": "3.6.3",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseLockFile = %v, want %v", got, want)
+	}
+}
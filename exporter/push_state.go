@@ -0,0 +1,44 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runFingerprint hashes the inputs that identify one run: the run ID, the plan path
+// and the apply log's modification time. A re-run of the same CI step yields the
+// same fingerprint; a new apply log does not.
+func runFingerprint(runID string, logs runLogs) string {
+	applyModTime := ""
+	if info, err := os.Stat(logs.applyLog); err == nil {
+		applyModTime = info.ModTime().UTC().String()
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{runID, logs.planJSON, applyModTime}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// pushState returns the run's fingerprint and whether PUSH_STATE_FILE records it
+// as pushed. Dry runs and FORCE_PUSH=true never count as pushed.
+func pushState(logs runLogs, dryRun bool) (fingerprint string, pushed bool) {
+	fingerprint = runFingerprint(os.Getenv("GITHUB_RUN_ID"), logs)
+	statePath := os.Getenv("PUSH_STATE_FILE")
+	pushed = statePath != "" && !dryRun && os.Getenv("FORCE_PUSH") != "true" && alreadyPushed(statePath, fingerprint)
+	return fingerprint, pushed
+}
+
+// alreadyPushed reports whether the state file records fingerprint as pushed.
+func alreadyPushed(statePath, fingerprint string) bool {
+	data, err := os.ReadFile(statePath)
+	return err == nil && strings.TrimSpace(string(data)) == fingerprint
+}
+
+// recordPush stores fingerprint in the state file, replacing any previous one.
+func recordPush(statePath, fingerprint string) error {
+	if err := os.WriteFile(statePath, []byte(fingerprint+"\n"), 0644); err != nil {
+		return fmt.Errorf("writing push state file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package exporter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRunAllSkipsAlreadyPushedRun(t *testing.T) {
+	dir := t.TempDir()
+	var notifications atomic.Int32
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notifications.Add(1)
+	}))
+	defer slack.Close()
+
+	t.Setenv("TERRAFORM_LOG_DIR", dir)
+	t.Setenv("OUTPUT", "textfile")
+	t.Setenv("TEXTFILE_PATH", filepath.Join(dir, "terraform.prom"))
+	t.Setenv("PUSH_STATE_FILE", filepath.Join(dir, "push-state"))
+	t.Setenv("SUMMARY_PROVIDER", "template")
+	t.Setenv("SLACK_WEBHOOK_URL", slack.URL)
+	t.Setenv("GITHUB_RUN_ID", "42")
+	for _, name := range []string{"DRY_RUN", "FORCE_PUSH", "SLACK_NOTIFY_ON", "SUMMARY_ENABLED", "TERRAFORM_PLAN_PATH", "TERRAFORM_PLAN_LOG_PATH", "TERRAFORM_APPLY_LOG_PATH", "TERRAFORM_REFRESH_LOG_PATH"} {
+		t.Setenv(name, "")
+	}
+	summaryPath := filepath.Join(dir, "terraform-gemini-summary-42.log")
+
+	if _, err := RunAll("42"); err != nil {
+		t.Fatalf("first RunAll: %v", err)
+	}
+	if _, err := os.Stat(summaryPath); err != nil {
+		t.Fatalf("first RunAll wrote no summary: %v", err)
+	}
+	if got := notifications.Load(); got != 1 {
+		t.Fatalf("first RunAll sent %d Slack notifications, want 1", got)
+	}
+
+	if err := os.Remove(summaryPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunAll("42"); !errors.Is(err, ErrAlreadyPushed) {
+		t.Fatalf("second RunAll: err = %v, want ErrAlreadyPushed", err)
+	}
+	if _, err := os.Stat(summaryPath); !os.IsNotExist(err) {
+		t.Errorf("second RunAll summarized again")
+	}
+	if got := notifications.Load(); got != 1 {
+		t.Errorf("second RunAll notified Slack again, %d notifications in total", got)
+	}
+
+	t.Setenv("FORCE_PUSH", "true")
+	if _, err := RunAll("42"); err != nil {
+		t.Fatalf("forced RunAll: %v", err)
+	}
+	if got := notifications.Load(); got != 2 {
+		t.Errorf("forced RunAll sent %d Slack notifications in total, want 2", got)
+	}
+}
+
+func TestCollectAndPushAlreadyPushed(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TEXTFILE_PATH", filepath.Join(dir, "terraform.prom"))
+	t.Setenv("PUSH_STATE_FILE", filepath.Join(dir, "push-state"))
+	t.Setenv("FORCE_PUSH", "")
+	t.Setenv("DRY_RUN", "")
+	cfg := Config{Outputs: []string{"textfile"}}
+
+	if _, err := CollectAndPush(cfg); err != nil {
+		t.Fatalf("first push: %v", err)
+	}
+	if _, err := CollectAndPush(cfg); !errors.Is(err, ErrAlreadyPushed) {
+		t.Fatalf("second push: err = %v, want ErrAlreadyPushed", err)
+	}
+}
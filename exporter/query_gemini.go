@@ -0,0 +1,7 @@
+package exporter
+
+// QueryGemini writes the summary of runID's logs with the configured
+// SUMMARY_PROVIDER; the name predates the other providers.
+func QueryGemini(runID string) (SummaryStats, error) {
+	return summarize(runID, logsForRun(runID))
+}
@@ -0,0 +1,84 @@
+package exporter
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const redactedPlaceholder = "[REDACTED]"
+
+// Secrets commonly found in Terraform error output. When a pattern has a capturing
+// group, the first group is kept and only the rest of the match is redacted.
+var defaultRedactPatterns = []string{
+	// AWS access key IDs
+	`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`,
+	// Authorization headers and bearer tokens
+	`(?i)(bearer\s+)[A-Za-z0-9\-._~+/]+=*`,
+	// PEM private key blocks
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+	// password = "..." / password: ... assignments
+	`(?i)(\b[a-z_]*password"?\s*[=:]\s*)("[^"]*"|\S+)`,
+}
+
+// loadRedactPatterns compiles the default patterns plus those in
+// REDACT_PATTERNS_FILE, one regular expression per line. Invalid patterns are
+// skipped with a warning.
+func loadRedactPatterns() []*regexp.Regexp {
+	sources := append([]string{}, defaultRedactPatterns...)
+	if path := os.Getenv("REDACT_PATTERNS_FILE"); path != "" {
+		extra, err := readPatternFile(path)
+		if err != nil {
+			slog.Warn("ignoring redaction patterns file", "path", path, "error", err)
+		}
+		sources = append(sources, extra...)
+	}
+
+	var patterns []*regexp.Regexp
+	for _, src := range sources {
+		re, err := regexp.Compile(src)
+		if err != nil {
+			slog.Warn("ignoring invalid redaction pattern", "pattern", src, "error", err)
+			continue
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns
+}
+
+// readPatternFile reads one pattern per line, skipping blank lines and # comments.
+func readPatternFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return patterns, nil
+}
+
+// redactSecrets masks every match of the patterns in text.
+func redactSecrets(text string, patterns []*regexp.Regexp) string {
+	for _, re := range patterns {
+		replacement := redactedPlaceholder
+		if re.NumSubexp() > 0 {
+			replacement = "${1}" + redactedPlaceholder
+		}
+		text = re.ReplaceAllString(text, replacement)
+	}
+	return text
+}
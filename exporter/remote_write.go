@@ -0,0 +1,127 @@
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteSink sends metrics using the Prometheus remote-write protocol
+// (snappy-compressed protobuf), e.g. to a Prometheus Agent or a Mimir/Cortex
+// /api/v1/push endpoint.
+type remoteWriteSink struct {
+	url string
+	// tenant is sent as X-Scope-OrgID when set.
+	tenant string
+	// username and password enable basic auth, e.g. for Grafana Cloud.
+	username, password string
+	// labels are attached to every series, like the Pushgateway grouping labels.
+	labels []label
+	client *http.Client
+}
+
+func (s *remoteWriteSink) Name() string { return "remote_write" }
+
+func (s *remoteWriteSink) Write(ctx context.Context, collectors []prometheus.Collector) error {
+	families, err := gather(collectors)
+	if err != nil {
+		return err
+	}
+
+	body := snappy.Encode(nil, encodeWriteRequest(families, s.labels, time.Now()))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	if s.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", s.tenant)
+	}
+	if s.username != "" {
+		req.SetBasicAuth(s.username, s.password)
+	}
+
+	client := s.client
+	if client == nil {
+		client = newHTTPClient(0)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("remote write returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// encodeWriteRequest encodes gathered metric families as a prometheus.WriteRequest
+// protobuf message. Only gauge, counter and untyped samples are supported.
+func encodeWriteRequest(families []*dto.MetricFamily, extra []label, ts time.Time) []byte {
+	var out []byte
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			var value float64
+			switch {
+			case m.GetGauge() != nil:
+				value = m.GetGauge().GetValue()
+			case m.GetCounter() != nil:
+				value = m.GetCounter().GetValue()
+			case m.GetUntyped() != nil:
+				value = m.GetUntyped().GetValue()
+			default:
+				slog.Warn("remote write skipping unsupported metric type", "metric", mf.GetName())
+				continue
+			}
+
+			labels := []label{{"__name__", mf.GetName()}}
+			labels = append(labels, extra...)
+			for _, lp := range m.GetLabel() {
+				labels = append(labels, label{lp.GetName(), lp.GetValue()})
+			}
+			out = protowire.AppendTag(out, 1, protowire.BytesType)
+			out = protowire.AppendBytes(out, encodeTimeSeries(labels, value, ts.UnixMilli()))
+		}
+	}
+	return out
+}
+
+func encodeTimeSeries(labels []label, value float64, tsMillis int64) []byte {
+	// Remote write requires labels sorted by name.
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+	var out []byte
+	for _, l := range labels {
+		var lb []byte
+		lb = protowire.AppendTag(lb, 1, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.name)
+		lb = protowire.AppendTag(lb, 2, protowire.BytesType)
+		lb = protowire.AppendString(lb, l.value)
+		out = protowire.AppendTag(out, 1, protowire.BytesType)
+		out = protowire.AppendBytes(out, lb)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(tsMillis))
+	out = protowire.AppendTag(out, 2, protowire.BytesType)
+	out = protowire.AppendBytes(out, sample)
+	return out
+}
@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// remoteWriteReceiver decodes remote-write requests into one
+// `{label="value",...} value` string per series.
+type remoteWriteReceiver struct {
+	*httptest.Server
+	mu      sync.Mutex
+	headers http.Header
+	series  []string
+	err     error
+}
+
+func newRemoteWriteReceiver() *remoteWriteReceiver {
+	rw := &remoteWriteReceiver{}
+	rw.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw.mu.Lock()
+		defer rw.mu.Unlock()
+		rw.headers = r.Header.Clone()
+		compressed, err := io.ReadAll(r.Body)
+		if err == nil {
+			var body []byte
+			if body, err = snappy.Decode(nil, compressed); err == nil {
+				rw.series, err = decodeWriteRequest(body)
+			}
+		}
+		if err != nil {
+			rw.err = err
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	return rw
+}
+
+// fields splits a protobuf message into its length-delimited and fixed64/varint
+// fields, keyed by field number.
+func fields(b []byte) (map[protowire.Number][][]byte, map[protowire.Number]uint64, error) {
+	bytesFields := map[protowire.Number][][]byte{}
+	numFields := map[protowire.Number]uint64{}
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, nil, protowire.ParseError(n)
+			}
+			bytesFields[num] = append(bytesFields[num], v)
+			b = b[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return nil, nil, protowire.ParseError(n)
+			}
+			numFields[num] = v
+			b = b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return nil, nil, protowire.ParseError(n)
+			}
+			numFields[num] = v
+			b = b[n:]
+		default:
+			return nil, nil, fmt.Errorf("unexpected wire type %d", typ)
+		}
+	}
+	return bytesFields, numFields, nil
+}
+
+func decodeWriteRequest(body []byte) ([]string, error) {
+	request, _, err := fields(body)
+	if err != nil {
+		return nil, err
+	}
+	var series []string
+	for _, ts := range request[1] {
+		tsFields, _, err := fields(ts)
+		if err != nil {
+			return nil, err
+		}
+		var labels []string
+		for _, l := range tsFields[1] {
+			lf, _, err := fields(l)
+			if err != nil {
+				return nil, err
+			}
+			labels = append(labels, fmt.Sprintf("%s=%q", lf[1][0], lf[2][0]))
+		}
+		if len(tsFields[2]) != 1 {
+			return nil, fmt.Errorf("series has %d samples, want 1", len(tsFields[2]))
+		}
+		_, sample, err := fields(tsFields[2][0])
+		if err != nil {
+			return nil, err
+		}
+		if sample[2] == 0 {
+			return nil, fmt.Errorf("sample has no timestamp")
+		}
+		series = append(series, fmt.Sprintf("{%s} %g", strings.Join(labels, ","), math.Float64frombits(sample[1])))
+	}
+	sort.Strings(series)
+	return series, nil
+}
+
+func TestRemoteWritePayload(t *testing.T) {
+	rw := newRemoteWriteReceiver()
+	defer rw.Close()
+
+	result := prometheus.NewGauge(prometheus.GaugeOpts{Name: "terraform_result", Help: "test"})
+	result.Set(1)
+	changes := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "terraform_changes", Help: "test"}, []string{"type", "action"})
+	changes.WithLabelValues("aws_s3_bucket", "create").Set(2)
+	changes.WithLabelValues("aws_instance", "delete").Set(1)
+
+	sink := &remoteWriteSink{url: rw.URL, tenant: "team-a", labels: []label{{"job", "terraform"}, {"instance", "42"}}}
+	if err := sink.Write(context.Background(), []prometheus.Collector{result, changes}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	if rw.err != nil {
+		t.Fatalf("decoding the request: %v", rw.err)
+	}
+	want := []string{
+		`{__name__="terraform_changes",action="create",instance="42",job="terraform",type="aws_s3_bucket"} 2`,
+		`{__name__="terraform_changes",action="delete",instance="42",job="terraform",type="aws_instance"} 1`,
+		`{__name__="terraform_result",instance="42",job="terraform"} 1`,
+	}
+	if !reflect.DeepEqual(rw.series, want) {
+		t.Errorf("series = %q, want %q", rw.series, want)
+	}
+	for name, want := range map[string]string{"Content-Encoding": "snappy", "Content-Type": "application/x-protobuf", "X-Scope-OrgID": "team-a"} {
+		if got := rw.headers.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package exporter
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type typeAction struct {
+	resourceType, action string
+}
+
+// countChangesByType tallies create/update/delete actions per resource type. A
+// replacement counts towards both delete and create for its type.
+func countChangesByType(changes []ResourceChange) map[typeAction]int {
+	counts := map[typeAction]int{}
+	for _, rc := range changes {
+		for _, action := range []string{"create", "update", "delete"} {
+			if contains(rc.Change.Actions, action) {
+				counts[typeAction{rc.Type, action}]++
+			}
+		}
+	}
+	return counts
+}
+
+// providerFromType returns the provider prefix of a resource type, e.g. "aws" for
+// "aws_instance", or "unknown" for types without one.
+func providerFromType(resourceType string) string {
+	provider, _, ok := strings.Cut(resourceType, "_")
+	if !ok || provider == "" {
+		return "unknown"
+	}
+	return provider
+}
+
+// countChangesByProvider is countChangesByType grouped by provider; the keys hold
+// the provider in place of the resource type.
+func countChangesByProvider(changes []ResourceChange) map[typeAction]int {
+	counts := map[typeAction]int{}
+	for key, count := range countChangesByType(changes) {
+		counts[typeAction{providerFromType(key.resourceType), key.action}] += count
+	}
+	return counts
+}
+
+// countDistinctProviders returns the number of distinct provider prefixes among the
+// plan's resource changes, no-ops and data sources included.
+func countDistinctProviders(changes []ResourceChange) int {
+	seen := map[string]bool{}
+	for _, rc := range changes {
+		seen[providerFromType(rc.Type)] = true
+	}
+	return len(seen)
+}
+
+// countDistinctModules returns the number of distinct modules among the plan's
+// resource changes, no-ops and data sources included; root-level resources count as
+// the "root" module.
+func countDistinctModules(changes []ResourceChange) int {
+	seen := map[string]bool{}
+	for _, rc := range changes {
+		module := rc.ModuleAddress
+		if module == "" {
+			module = "root"
+		}
+		seen[module] = true
+	}
+	return len(seen)
+}
+
+// resourceChangeCollectors returns one terraform_resource_changes gauge per
+// type/action series.
+func resourceChangeCollectors(counts map[typeAction]int) []prometheus.Collector {
+	var collectors []prometheus.Collector
+	for key, count := range counts {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{
+			Name:        metricName("terraform_resource_changes"),
+			Help:        "Planned resource changes by resource type and action",
+			ConstLabels: prometheus.Labels{"type": key.resourceType, "action": key.action},
+		})
+		g.Set(float64(count))
+		collectors = append(collectors, g)
+	}
+	return collectors
+}
@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestResourceChangeCollectors(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	m := parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", replacePlanJSON)})
+
+	reg := prometheus.NewPedanticRegistry()
+	for _, c := range resourceChangeCollectors(m.ChangesByType) {
+		if err := reg.Register(c); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	if len(families) != 1 || families[0].GetName() != "terraform_resource_changes" {
+		t.Fatalf("gathered %d families, want terraform_resource_changes only", len(families))
+	}
+	got := map[string]float64{}
+	for _, metric := range families[0].GetMetric() {
+		labels := map[string]string{}
+		for _, lp := range metric.GetLabel() {
+			labels[lp.GetName()] = lp.GetValue()
+		}
+		got[labels["type"]+"/"+labels["action"]] = metric.GetGauge().GetValue()
+	}
+	// Replacements count towards both create and delete; the no-op and the data
+	// source have no series
+	want := map[string]float64{
+		"aws_instance/create":  1,
+		"aws_instance/delete":  1,
+		"aws_lb/create":        1,
+		"aws_lb/delete":        1,
+		"aws_s3_bucket/create": 1,
+		"aws_iam_role/update":  1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("terraform_resource_changes = %v, want %v", got, want)
+	}
+}
+
+// multiCloudPlanJSON changes AWS and GCP resources in the root module and two
+// child modules.
+const multiCloudPlanJSON = `{
+  "format_version": "1.2",
+  "resource_changes": [
+    {"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket",
+     "change": {"actions": ["create"]}},
+    {"address": "module.web.aws_instance.this", "module_address": "module.web", "mode": "managed", "type": "aws_instance",
+     "change": {"actions": ["delete", "create"]}},
+    {"address": "module.data.google_storage_bucket.raw", "module_address": "module.data", "mode": "managed", "type": "google_storage_bucket",
+     "change": {"actions": ["update"]}},
+    {"address": "module.data.google_bigquery_dataset.raw", "module_address": "module.data", "mode": "managed", "type": "google_bigquery_dataset",
+     "change": {"actions": ["create"]}},
+    {"address": "terraform_data.marker", "mode": "managed", "type": "terraform_data",
+     "change": {"actions": ["no-op"]}},
+    {"address": "null.marker", "mode": "managed", "type": "null",
+     "change": {"actions": ["delete"]}}
+  ]
+}`
+
+func TestProviderChanges(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	m := parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", multiCloudPlanJSON)})
+
+	got := map[string]int{}
+	for key, count := range m.ChangesByProvider {
+		got[key.resourceType+"/"+key.action] = count
+	}
+	// The no-op has no series; a type without a provider prefix is "unknown"
+	want := map[string]int{
+		"aws/create":     2,
+		"aws/delete":     1,
+		"google/create":  1,
+		"google/update":  1,
+		"unknown/delete": 1,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("terraform_provider_changes = %v, want %v", got, want)
+	}
+	if series := gatherLabels(t, buildCollectors(m, newMetricRegistry(nil, false)), "terraform_provider_changes"); len(series) != len(want) {
+		t.Errorf("terraform_provider_changes has %d series, want %d", len(series), len(want))
+	}
+}
+
+func TestDistinctProvidersAndModules(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	m := parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", multiCloudPlanJSON)})
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+
+	// aws and google in the modules; the root adds the no-op terraform_data and the
+	// unprefixed "null" type
+	for name, want := range map[string]float64{
+		"terraform_distinct_providers": 4,
+		"terraform_distinct_modules":   3,
+	} {
+		if got := values[name]; len(got) != 1 || got[0] != want {
+			t.Errorf("%s = %v, want [%v]", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package exporter
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPushRetries        = 3
+	defaultPushTimeoutSeconds = 15
+)
+
+// retryBackoff is the initial delay between attempts, doubled after each retry.
+const retryBackoff = 500 * time.Millisecond
+
+// withRetry calls fn up to attempts times, sleeping with exponential backoff between
+// attempts, as long as retryable reports the error as transient.
+func withRetry(attempts int, sleep func(time.Duration), retryable func(error) bool, fn func() error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+	delay := retryBackoff
+	var err error
+	for i := 1; i <= attempts; i++ {
+		if err = fn(); err == nil || !retryable(err) {
+			return err
+		}
+		if i < attempts {
+			slog.Warn("attempt failed, retrying", "attempt", i, "attempts", attempts, "backoff", delay, "error", err)
+			sleep(delay)
+			delay *= 2
+		}
+	}
+	return err
+}
+
+var pushStatusPattern = regexp.MustCompile(`unexpected status code (\d+)`)
+
+// isRetryablePushError reports whether a Pushgateway push failed with a network
+// error or a 5xx response. 4xx responses and other errors are not retried.
+func isRetryablePushError(err error) bool {
+	if m := pushStatusPattern.FindStringSubmatch(err.Error()); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return code >= 500
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
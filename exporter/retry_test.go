@@ -0,0 +1,142 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statusSequence serves the given status codes in order, repeating the last one,
+// and counts the requests it received.
+func statusSequence(codes ...int) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		code := codes[len(codes)-1]
+		if n <= len(codes) {
+			code = codes[n-1]
+		}
+		w.WriteHeader(code)
+	}))
+	return srv, &requests
+}
+
+// testPushgatewaySink returns a sink for url that records its backoff sleeps
+// instead of sleeping.
+func testPushgatewaySink(url string, retries int, slept *[]time.Duration) *pushgatewaySink {
+	return &pushgatewaySink{
+		url:      url,
+		job:      "terraform",
+		grouping: []label{{"instance", "1"}},
+		retries:  retries,
+		sleep:    func(d time.Duration) { *slept = append(*slept, d) },
+		timeout:  5 * time.Second,
+	}
+}
+
+func testGauge() prometheus.Collector {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: "terraform_result", Help: "test"})
+	g.Set(1)
+	return g
+}
+
+func TestPushRetriesOn5xx(t *testing.T) {
+	srv, requests := statusSequence(http.StatusServiceUnavailable, http.StatusOK)
+	defer srv.Close()
+
+	var slept []time.Duration
+	sink := testPushgatewaySink(srv.URL, 3, &slept)
+	if err := sink.Write(context.Background(), []prometheus.Collector{testGauge()}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+	if want := []time.Duration{retryBackoff}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+}
+
+func TestPushDoesNotRetry4xx(t *testing.T) {
+	srv, requests := statusSequence(http.StatusBadRequest, http.StatusOK)
+	defer srv.Close()
+
+	var slept []time.Duration
+	sink := testPushgatewaySink(srv.URL, 3, &slept)
+	if err := sink.Write(context.Background(), []prometheus.Collector{testGauge()}); err == nil {
+		t.Fatal("Write succeeded despite a 400")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+	if len(slept) != 0 {
+		t.Errorf("slept %v before giving up on a 4xx", slept)
+	}
+}
+
+func TestPushRetryCap(t *testing.T) {
+	srv, requests := statusSequence(http.StatusBadGateway)
+	defer srv.Close()
+
+	var slept []time.Duration
+	sink := testPushgatewaySink(srv.URL, 3, &slept)
+	if err := sink.Write(context.Background(), []prometheus.Collector{testGauge()}); err == nil {
+		t.Fatal("Write succeeded although every attempt failed")
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+	if want := []time.Duration{retryBackoff, 2 * retryBackoff}; !reflect.DeepEqual(slept, want) {
+		t.Errorf("slept %v, want %v", slept, want)
+	}
+}
+
+func TestPushNoRetryAfterDeadline(t *testing.T) {
+	srv, requests := statusSequence(http.StatusServiceUnavailable)
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var slept []time.Duration
+	sink := testPushgatewaySink(srv.URL, 3, &slept)
+	sink.sleep = func(time.Duration) { cancel() }
+	if err := sink.Write(ctx, []prometheus.Collector{testGauge()}); err == nil {
+		t.Fatal("Write succeeded although every attempt failed")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1 once the context is done", got)
+	}
+}
+
+func TestIsRetryablePushError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("unexpected status code 503 while pushing to http://pg/metrics"), true},
+		{errors.New("unexpected status code 500 while pushing to http://pg/metrics"), true},
+		{errors.New("unexpected status code 400 while pushing to http://pg/metrics"), false},
+		{errors.New("unexpected status code 404 while pushing to http://pg/metrics"), false},
+		{fmt.Errorf("push: %w", &timeoutError{}), true},
+		{errors.New("collector registration failed"), false},
+	}
+	for _, tt := range tests {
+		if got := isRetryablePushError(tt.err); got != tt.want {
+			t.Errorf("isRetryablePushError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// timeoutError is a net.Error, as returned for a connection timeout.
+type timeoutError struct{}
+
+func (*timeoutError) Error() string   { return "i/o timeout" }
+func (*timeoutError) Timeout() bool   { return true }
+func (*timeoutError) Temporary() bool { return true }
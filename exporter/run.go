@@ -0,0 +1,196 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// runLogs holds the plan and log paths of one Terraform run.
+type runLogs struct {
+	planJSON   string
+	planLog    string
+	applyLog   string
+	refreshLog string
+}
+
+// Config selects the Terraform run to export and where its metrics go. Settings not
+// covered here, such as the Pushgateway address, grouping labels or METRIC_PREFIX,
+// are read from the environment as for the command-line tool.
+type Config struct {
+	PlanPath       string
+	PlanLogPath    string
+	ApplyLogPath   string
+	RefreshLogPath string
+	// Outputs lists the metric outputs, e.g. "pushgateway" or "textfile"; empty
+	// means OUTPUT from the environment.
+	Outputs []string
+	// DryRun prints the metrics to stdout instead of writing them; DRY_RUN=true
+	// enables it as well.
+	DryRun bool
+}
+
+// ConfigFromEnv builds a Config from TERRAFORM_PLAN_PATH and the other
+// TERRAFORM_*_PATH variables, OUTPUT and DRY_RUN.
+func ConfigFromEnv() Config {
+	logs := logsFromEnv()
+	return Config{
+		PlanPath:       logs.planJSON,
+		PlanLogPath:    logs.planLog,
+		ApplyLogPath:   logs.applyLog,
+		RefreshLogPath: logs.refreshLog,
+		Outputs:        outputsFromEnv(),
+		DryRun:         dryRunEnabled(),
+	}
+}
+
+func (c Config) runLogs() runLogs {
+	return runLogs{planJSON: c.PlanPath, planLog: c.PlanLogPath, applyLog: c.ApplyLogPath, refreshLog: c.RefreshLogPath}
+}
+
+// CollectAndPush parses the run described by cfg and writes its metrics to the
+// configured outputs. It returns the parsed metrics even when writing failed;
+// Metrics.Succeeded reports whether the Terraform run itself succeeded. A run that
+// PUSH_STATE_FILE records as pushed returns ErrAlreadyPushed; a configured plan
+// that could not be parsed returns ErrPlanParse after pushing.
+func CollectAndPush(cfg Config) (Metrics, error) {
+	return collectMetrics(cfg, nil)
+}
+
+// PushAndSummarize is the default mode: it pushes the metrics of cfg and then, unless
+// RUN_SUMMARY=false, summarizes runID with QueryGemini before notifying Slack. A
+// failed summary is logged and only returned when SUMMARY_REQUIRED=true.
+func PushAndSummarize(cfg Config, runID string) (Metrics, error) {
+	m, err := CollectAndPush(cfg)
+	if !metricsPushed(err) {
+		return m, err
+	}
+	var stats SummaryStats
+	if os.Getenv("RUN_SUMMARY") != "false" {
+		var summaryErr error
+		stats, summaryErr = QueryGemini(runID)
+		if summaryErr != nil {
+			if os.Getenv("SUMMARY_REQUIRED") == "true" {
+				return m, errors.Join(err, fmt.Errorf("generating summary failed: %w", summaryErr))
+			}
+			slog.Warn("generating summary failed", "error", summaryErr)
+		}
+	}
+	NotifySlack(runID, m, stats.Text())
+	return m, err
+}
+
+func logsFromEnv() runLogs {
+	return runLogs{
+		planJSON:   os.Getenv("TERRAFORM_PLAN_PATH"),
+		planLog:    os.Getenv("TERRAFORM_PLAN_LOG_PATH"),
+		applyLog:   os.Getenv("TERRAFORM_APPLY_LOG_PATH"),
+		refreshLog: os.Getenv("TERRAFORM_REFRESH_LOG_PATH"),
+	}
+}
+
+// logsForRun locates the logs of runID. Paths from the environment take precedence,
+// otherwise the terraform-<phase>-<runID> naming convention is used inside
+// TERRAFORM_LOG_DIR. The plan JSON and apply log are only used when present, so a
+// run with just a text plan log reports no plan parse error and plan-only runs are
+// not treated as applies.
+func logsForRun(runID string) runLogs {
+	logs := logsFromEnv()
+	if logs.planJSON == "" {
+		path := inLogDir(fmt.Sprintf("terraform-plan-%s.json", runID))
+		if _, err := os.Stat(path); err == nil {
+			logs.planJSON = path
+		}
+	}
+	if logs.planLog == "" {
+		logs.planLog = inLogDir(fmt.Sprintf("terraform-plan-%s.log", runID))
+	}
+	if logs.refreshLog == "" {
+		logs.refreshLog = inLogDir(fmt.Sprintf("terraform-refresh-%s.log", runID))
+	}
+	if logs.applyLog == "" {
+		path := inLogDir(fmt.Sprintf("terraform-apply-%s.log", runID))
+		if _, err := os.Stat(path); err == nil {
+			logs.applyLog = path
+		}
+	}
+	return logs
+}
+
+// inLogDir resolves a relative file name against TERRAFORM_LOG_DIR (default: the
+// working directory). Absolute paths are returned unchanged.
+func inLogDir(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(os.Getenv("TERRAFORM_LOG_DIR"), name)
+}
+
+// RunAll is the combined "run <runID>" mode: it summarizes the run and pushes the
+// Terraform metrics together with the summary metrics in a single push. It reports
+// whether the Terraform run succeeded. A run that PUSH_STATE_FILE records as pushed
+// is neither summarized nor notified again and returns ErrAlreadyPushed.
+func RunAll(runID string) (bool, error) {
+	logs := logsForRun(runID)
+	cfg := ConfigFromEnv()
+	cfg.PlanPath, cfg.PlanLogPath, cfg.ApplyLogPath, cfg.RefreshLogPath = logs.planJSON, logs.planLog, logs.applyLog, logs.refreshLog
+
+	// A re-run of a pushed run returns ErrAlreadyPushed without summarizing again
+	if _, pushed := pushState(logs, cfg.DryRun); pushed {
+		m, err := collectMetrics(cfg, nil)
+		return m.Succeeded, err
+	}
+
+	stats, summaryErr := summarize(runID, logs)
+	if summaryErr != nil {
+		slog.Warn("summarization failed", "error", summaryErr)
+	}
+	m, err := collectMetrics(cfg, summaryCollectors(stats, summaryErr))
+	if metricsPushed(err) {
+		NotifySlack(runID, m, stats.text)
+	}
+	return m.Succeeded, err
+}
+
+// metricsPushed reports whether collectMetrics pushed the metrics despite err, i.e.
+// err is nil or only reports an unparseable plan.
+func metricsPushed(err error) bool {
+	return err == nil || errors.Is(err, ErrPlanParse) && !errors.Is(err, ErrPushFailed)
+}
+
+// DeleteMetrics deletes the run's Pushgateway group (PUSH_MODE=delete), using the
+// same job and grouping labels as a push. Like a push, it is bounded by
+// EXPORTER_TIMEOUT_SECONDS.
+func DeleteMetrics() error {
+	if err := validateEnv("delete", nil, false); err != nil {
+		return err
+	}
+	ctx, cancel, timeout := exporterContext()
+	defer cancel()
+	if err := newPushgatewaySinks(groupingFromEnv(), nil).Delete(ctx); err != nil {
+		return deadlineError(fmt.Errorf("%w: %w", ErrPushFailed, err), timeout)
+	}
+	return nil
+}
+
+func summaryCollectors(stats SummaryStats, summaryErr error) []prometheus.Collector {
+	newGauge := func(name, help string, value float64) prometheus.Collector {
+		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: metricName(name), Help: help})
+		g.Set(value)
+		return g
+	}
+
+	success := 0.0
+	if summaryErr == nil && stats.ai {
+		success = 1
+	}
+	return []prometheus.Collector{
+		newGauge("terraform_summary_success", "1=AI summary generated, 0=failed or unavailable", success),
+		newGauge("terraform_summary_tokens_total", "Tokens used by the AI summary", float64(stats.tokens)),
+		newGauge("terraform_summary_duration_seconds", "Time taken to generate the summary", stats.duration.Seconds()),
+	}
+}
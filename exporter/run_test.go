@@ -0,0 +1,188 @@
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestInLogDir(t *testing.T) {
+	abs := filepath.Join(t.TempDir(), "plan.json")
+	tests := []struct {
+		name, dir, file, want string
+	}{
+		{"unset dir", "", "terraform-plan-1.json", "terraform-plan-1.json"},
+		{"absolute dir", "/var/log/terraform", "terraform-plan-1.json", filepath.Join("/var/log/terraform", "terraform-plan-1.json")},
+		{"relative dir", "logs/terraform", "terraform-plan-1.json", filepath.Join("logs", "terraform", "terraform-plan-1.json")},
+		{"absolute file", "/var/log/terraform", abs, abs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TERRAFORM_LOG_DIR", tt.dir)
+			if got := inLogDir(tt.file); got != tt.want {
+				t.Errorf("inLogDir(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogsForRun(t *testing.T) {
+	for _, name := range []string{"TERRAFORM_PLAN_PATH", "TERRAFORM_PLAN_LOG_PATH", "TERRAFORM_APPLY_LOG_PATH", "TERRAFORM_REFRESH_LOG_PATH"} {
+		t.Setenv(name, "")
+	}
+	dir := t.TempDir()
+	t.Setenv("TERRAFORM_LOG_DIR", dir)
+
+	logs := logsForRun("7")
+	if logs.planJSON != "" {
+		t.Errorf("planJSON = %q without a plan JSON on disk", logs.planJSON)
+	}
+	if want := filepath.Join(dir, "terraform-refresh-7.log"); logs.refreshLog != want {
+		t.Errorf("refreshLog = %q, want %q", logs.refreshLog, want)
+	}
+	if logs.applyLog != "" {
+		t.Errorf("applyLog = %q without an apply log on disk", logs.applyLog)
+	}
+
+	applyLog := filepath.Join(dir, "terraform-apply-7.log")
+	if err := os.WriteFile(applyLog, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := logsForRun("7").applyLog; got != applyLog {
+		t.Errorf("applyLog = %q, want %q", got, applyLog)
+	}
+	planJSON := filepath.Join(dir, "terraform-plan-7.json")
+	if err := os.WriteFile(planJSON, []byte("{}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := logsForRun("7").planJSON; got != planJSON {
+		t.Errorf("planJSON = %q, want %q", got, planJSON)
+	}
+
+	// Explicit paths win over the log directory
+	t.Setenv("TERRAFORM_PLAN_PATH", "/plans/plan.json")
+	if got := logsForRun("7").planJSON; got != "/plans/plan.json" {
+		t.Errorf("planJSON = %q, want the TERRAFORM_PLAN_PATH value", got)
+	}
+}
+
+// orderRecorder is a fake Pushgateway, chat-completions API and Slack webhook in
+// one server that records which step called it, in order. Consecutive pushes, such
+// as the last-success group, are recorded as one step.
+type orderRecorder struct {
+	*httptest.Server
+	mu    sync.Mutex
+	steps []string
+}
+
+func newOrderRecorder() *orderRecorder {
+	rec := &orderRecorder{}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/metrics/"):
+			if n := len(rec.steps); n == 0 || rec.steps[n-1] != "push" {
+				rec.steps = append(rec.steps, "push")
+			}
+		case r.URL.Path == "/llm/chat/completions":
+			rec.steps = append(rec.steps, "summary")
+			fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "1 added"}}], "usage": {"total_tokens": 12}}`)
+		case r.URL.Path == "/slack":
+			rec.steps = append(rec.steps, "slack")
+		}
+	}))
+	return rec
+}
+
+func (rec *orderRecorder) Steps() []string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]string(nil), rec.steps...)
+}
+
+func setOrderEnv(t *testing.T, rec *orderRecorder) {
+	t.Helper()
+	setPushgatewayEnv(t, rec.URL)
+	for _, name := range []string{"DRY_RUN", "OUTPUT", "BACKEND", "PUSH_STATE_FILE", "SLACK_NOTIFY_ON", "SUMMARY_ENABLED", "SUMMARY_REQUIRED", "RUN_SUMMARY", "GEMINI_PROMPT_FILE", "TERRAFORM_REFRESH_LOG_PATH"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("TERRAFORM_LOG_DIR", t.TempDir())
+	t.Setenv("SUMMARY_PROVIDER", "openai")
+	t.Setenv("LLM_BASE_URL", rec.URL+"/llm")
+	t.Setenv("LLM_API_KEY", "key")
+	t.Setenv("SLACK_WEBHOOK_URL", rec.URL+"/slack")
+	t.Setenv("TERRAFORM_PLAN_PATH", writeTestFile(t, "plan.json", `{"resource_changes": [{"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "change": {"actions": ["create"]}}]}`))
+	t.Setenv("TERRAFORM_PLAN_LOG_PATH", writeTestFile(t, "plan.log", "Plan: 1 to add, 0 to change, 0 to destroy.\n"))
+	t.Setenv("TERRAFORM_APPLY_LOG_PATH", writeTestFile(t, "apply.log", "Apply complete! Resources: 1 added, 0 changed, 0 destroyed.\n"))
+}
+
+func TestPushAndSummarizeOrder(t *testing.T) {
+	tests := []struct {
+		runSummary string
+		want       []string
+	}{
+		{"", []string{"push", "summary", "slack"}},
+		{"true", []string{"push", "summary", "slack"}},
+		{"false", []string{"push", "slack"}},
+	}
+	for _, tt := range tests {
+		t.Run("RUN_SUMMARY="+tt.runSummary, func(t *testing.T) {
+			rec := newOrderRecorder()
+			defer rec.Close()
+			setOrderEnv(t, rec)
+			t.Setenv("RUN_SUMMARY", tt.runSummary)
+
+			m, err := PushAndSummarize(ConfigFromEnv(), "42")
+			if err != nil {
+				t.Fatalf("PushAndSummarize: %v", err)
+			}
+			// The plan and the apply log are both read before the push
+			if m.ToAdd != 1 || m.Added != 1 || m.RunType != "apply" {
+				t.Errorf("to add = %d, added = %d, run type = %q; want the plan and apply read", m.ToAdd, m.Added, m.RunType)
+			}
+			if got := rec.Steps(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("steps = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPushAndSummarizeSkipsSummaryAfterFailedPush(t *testing.T) {
+	rec := newOrderRecorder()
+	defer rec.Close()
+	setOrderEnv(t, rec)
+	gw := newRecordingPushgateway(http.StatusServiceUnavailable)
+	defer gw.Close()
+	t.Setenv("PUSHGATEWAY_URL", gw.URL)
+
+	if _, err := PushAndSummarize(ConfigFromEnv(), "42"); !errors.Is(err, ErrPushFailed) {
+		t.Fatalf("err = %v, want ErrPushFailed", err)
+	}
+	if got := rec.Steps(); len(got) != 0 {
+		t.Errorf("steps after a failed push = %v, want none", got)
+	}
+}
+
+func TestPushAndSummarizeSummaryRequired(t *testing.T) {
+	rec := newOrderRecorder()
+	defer rec.Close()
+	setOrderEnv(t, rec)
+	t.Setenv("LLM_BASE_URL", rec.URL+"/missing")
+
+	if _, err := PushAndSummarize(ConfigFromEnv(), "42"); err != nil {
+		t.Fatalf("optional summary failure returned %v", err)
+	}
+	t.Setenv("SUMMARY_REQUIRED", "true")
+	_, err := PushAndSummarize(ConfigFromEnv(), "42")
+	if err == nil || errors.Is(err, ErrPushFailed) {
+		t.Fatalf("err = %v, want a summary failure", err)
+	}
+}
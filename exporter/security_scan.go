@@ -0,0 +1,61 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type securityFinding struct {
+	Severity *string `json:"severity"`
+}
+
+// tfsec: {"results": [{"severity": "HIGH", ...}]}
+type tfsecReport struct {
+	Results []securityFinding `json:"results"`
+}
+
+// checkov: {"results": {"failed_checks": [{"severity": "HIGH", ...}]}}, or a list of
+// such reports when several frameworks were scanned.
+type checkovReport struct {
+	Results struct {
+		FailedChecks []securityFinding `json:"failed_checks"`
+	} `json:"results"`
+}
+
+// parseSecurityScan reads tfsec or Checkov JSON output and returns failed-check
+// counts keyed by lower-cased severity. Findings without a severity count as "unknown".
+func parseSecurityScan(path string) (map[string]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []securityFinding
+	var tfsec tfsecReport
+	var checkov checkovReport
+	var checkovList []checkovReport
+	switch {
+	case json.Unmarshal(data, &tfsec) == nil && tfsec.Results != nil:
+		findings = tfsec.Results
+	case json.Unmarshal(data, &checkov) == nil:
+		findings = checkov.Results.FailedChecks
+	case json.Unmarshal(data, &checkovList) == nil:
+		for _, r := range checkovList {
+			findings = append(findings, r.Results.FailedChecks...)
+		}
+	default:
+		return nil, fmt.Errorf("unrecognised security scan format in %s", path)
+	}
+
+	counts := map[string]int{}
+	for _, f := range findings {
+		severity := "unknown"
+		if f.Severity != nil && *f.Severity != "" {
+			severity = strings.ToLower(*f.Severity)
+		}
+		counts[severity]++
+	}
+	return counts, nil
+}
@@ -0,0 +1,46 @@
+package exporter
+
+import "reflect"
+
+// hasSensitiveChange reports whether any attribute marked sensitive in the plan
+// (before_sensitive or after_sensitive) differs between before and after, or only
+// becomes known after apply.
+func hasSensitiveChange(rc ResourceChange) bool {
+	c := rc.Change
+	return sensitiveDiffers(c.BeforeSensitive, c.Before, c.After, c.AfterUnknown) ||
+		sensitiveDiffers(c.AfterSensitive, c.Before, c.After, c.AfterUnknown)
+}
+
+func sensitiveDiffers(mask, before, after, unknown interface{}) bool {
+	switch m := mask.(type) {
+	case bool:
+		return m && (hasUnknownValues(unknown) || !reflect.DeepEqual(before, after))
+	case map[string]interface{}:
+		for key, child := range m {
+			if sensitiveDiffers(child, mapValue(before, key), mapValue(after, key), mapValue(unknown, key)) {
+				return true
+			}
+		}
+	case []interface{}:
+		for i, child := range m {
+			if sensitiveDiffers(child, sliceValue(before, i), sliceValue(after, i), sliceValue(unknown, i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func mapValue(v interface{}, key string) interface{} {
+	if m, ok := v.(map[string]interface{}); ok {
+		return m[key]
+	}
+	return nil
+}
+
+func sliceValue(v interface{}, i int) interface{} {
+	if s, ok := v.([]interface{}); ok && i < len(s) {
+		return s[i]
+	}
+	return nil
+}
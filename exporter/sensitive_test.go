@@ -0,0 +1,48 @@
+package exporter
+
+import "testing"
+
+func TestSensitiveChanges(t *testing.T) {
+	tests := []struct {
+		name, change string
+		want         float64
+	}{
+		{
+			"sensitive attribute changes",
+			`{"actions": ["update"], "before": {"username": "admin", "password": "old"}, "after": {"username": "admin", "password": "new"},
+			  "after_unknown": {}, "before_sensitive": {"password": true}, "after_sensitive": {"password": true}}`,
+			1,
+		},
+		{
+			"sensitive attribute becomes unknown",
+			`{"actions": ["update"], "before": {"password": "old"}, "after": {},
+			  "after_unknown": {"password": true}, "before_sensitive": {"password": true}, "after_sensitive": {"password": true}}`,
+			1,
+		},
+		{
+			"only a non-sensitive attribute changes",
+			`{"actions": ["update"], "before": {"username": "admin", "password": "same"}, "after": {"username": "root", "password": "same"},
+			  "after_unknown": {}, "before_sensitive": {"password": true}, "after_sensitive": {"password": true}}`,
+			0,
+		},
+		{
+			"no sensitive attributes",
+			`{"actions": ["update"], "before": {"username": "admin"}, "after": {"username": "root"},
+			  "after_unknown": {}, "before_sensitive": {}, "after_sensitive": {}}`,
+			0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("EXTRA_METRICS_FILE", "")
+			plan := `{"format_version": "1.2", "resource_changes": [
+			  {"address": "aws_db_instance.main", "mode": "managed", "type": "aws_db_instance", "change": ` + tt.change + `}]}`
+			m := parseMetrics(runLogs{planJSON: writeTestFile(t, "plan.json", plan)})
+			values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+			if got := values["terraform_sensitive_changes"]; len(got) != 1 || got[0] != tt.want {
+				t.Errorf("terraform_sensitive_changes = %v, want [%v]", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,501 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// MetricSink is a destination the collected metrics are written to.
+type MetricSink interface {
+	Name() string
+	Write(ctx context.Context, collectors []prometheus.Collector) error
+}
+
+type label struct {
+	name, value string
+}
+
+// pushGrouping is the job and grouping labels shared by every output.
+type pushGrouping struct {
+	job    string
+	labels []label
+	// lastSuccess is the grouping of terraform_last_success_timestamp.
+	lastSuccess []label
+}
+
+// groupingFromEnv builds the sanitized job and grouping labels. Push and delete
+// both use it so a delete always targets exactly the group that was pushed.
+func groupingFromEnv() pushGrouping {
+	maxLabelLen := envInt("MAX_LABEL_VALUE_LENGTH", defaultMaxLabelValueLength)
+	job, err := jobName()
+	if err != nil {
+		// validateEnv has already rejected this for pushes; keep the raw value
+		job = os.Getenv("PUSHGATEWAY_JOB")
+	}
+	job = sanitizeLabelValue(job, maxLabelLen)
+	workflowName := os.Getenv("GITHUB_WORKFLOW")
+	labels := []label{
+		{"instance", instanceLabel()},
+		{"commit_message", os.Getenv("COMMIT_MESSAGE")},
+		{"workflow_name", workflowName},
+		{"job", job},
+		{"branch", firstEnv(branchSources)},
+		{"pr_number", os.Getenv("PR_NUMBER")},
+	}
+	labels = append(labels, parseExtraLabels(os.Getenv("EXTRA_GROUPING_LABELS"), labels)...)
+	return pushGrouping{
+		job:    job,
+		labels: omitEmptyLabels(sanitizeLabels(labels, maxLabelLen)),
+		lastSuccess: omitEmptyLabels(sanitizeLabels([]label{
+			{"workflow_name", workflowName},
+			{"job", job},
+		}, maxLabelLen)),
+	}
+}
+
+// branchSources are tried in order for the branch grouping label: an explicit
+// value, then GitHub's pull request source branch and its ref name.
+var branchSources = []string{"GIT_BRANCH", "GITHUB_HEAD_REF", "GITHUB_REF_NAME"}
+
+// firstEnv returns the value of the first set variable in names, or "".
+func firstEnv(names []string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// jobName returns PUSHGATEWAY_JOB, rendered as a text/template when it contains
+// "{{", e.g. "tf_{{.ENVIRONMENT}}_{{.GITHUB_REPOSITORY}}". The template can use
+// every environment variable by name; unset ones render as "".
+func jobName() (string, error) {
+	raw := os.Getenv("PUSHGATEWAY_JOB")
+	if !strings.Contains(raw, "{{") {
+		return raw, nil
+	}
+	tmpl, err := template.New("job").Option("missingkey=zero").Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("parsing PUSHGATEWAY_JOB template: %w", err)
+	}
+	env := map[string]string{}
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			env[name] = value
+		}
+	}
+	var job strings.Builder
+	if err := tmpl.Execute(&job, env); err != nil {
+		return "", fmt.Errorf("rendering PUSHGATEWAY_JOB template: %w", err)
+	}
+	if strings.TrimSpace(job.String()) == "" {
+		return "", fmt.Errorf("PUSHGATEWAY_JOB template %q rendered an empty job name", raw)
+	}
+	return job.String(), nil
+}
+
+// instanceSources are tried in order for the instance grouping label: an explicit
+// value, then the run/pipeline/build ID of GitHub Actions, GitLab CI and Jenkins.
+var instanceSources = []string{"INSTANCE_LABEL", "GITHUB_RUN_ID", "CI_PIPELINE_ID", "BUILD_NUMBER"}
+
+// instanceLabel returns the instance grouping label from the first set variable of
+// instanceSources, falling back to the hostname for local runs.
+func instanceLabel() string {
+	for _, name := range instanceSources {
+		if v := os.Getenv(name); v != "" {
+			slog.Debug("instance label", "source", name, "value", v)
+			return v
+		}
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		slog.Warn("no instance label source set and hostname unavailable", "error", err)
+		return ""
+	}
+	slog.Info("instance label taken from hostname", "value", host)
+	return host
+}
+
+// pushgatewaySink pushes metrics to a Prometheus Pushgateway under a fixed grouping.
+type pushgatewaySink struct {
+	url      string
+	job      string
+	grouping []label
+	// lastSuccess is pushed to its own group and is nil when the run failed.
+	lastSuccess prometheus.Collector
+	// lastSuccessGrouping is the grouping used for lastSuccess.
+	lastSuccessGrouping []label
+
+	username, password string
+	bearerToken        string
+
+	// retries is the number of push attempts; sleep waits between them and
+	// defaults to time.Sleep.
+	retries int
+	sleep   func(time.Duration)
+	// timeout bounds each push request, including DNS resolution and connecting.
+	timeout time.Duration
+
+	// add uses POST (replace only same-named metrics) instead of PUT (replace the group).
+	add bool
+}
+
+// pushgatewayGroup pushes to every Pushgateway in PUSHGATEWAY_URL, e.g. an HA pair.
+// Unless requireAll is set, success on at least one of them is enough.
+type pushgatewayGroup struct {
+	sinks      []*pushgatewaySink
+	requireAll bool
+}
+
+// newPushgatewaySinks returns one sink per configured Pushgateway, all sharing the
+// same grouping.
+func newPushgatewaySinks(grouping pushGrouping, lastSuccess prometheus.Collector) *pushgatewayGroup {
+	group := &pushgatewayGroup{requireAll: os.Getenv("PUSH_REQUIRE_ALL") == "true"}
+	for _, url := range pushgatewayURLs() {
+		group.sinks = append(group.sinks, newPushgatewaySink(url, grouping, lastSuccess))
+	}
+	return group
+}
+
+func (g *pushgatewayGroup) Name() string { return "pushgateway" }
+
+func (g *pushgatewayGroup) Write(ctx context.Context, collectors []prometheus.Collector) error {
+	sinks := make([]MetricSink, 0, len(g.sinks))
+	for _, s := range g.sinks {
+		sinks = append(sinks, s)
+	}
+	return writeToSinks(ctx, sinks, collectors, g.requireAll)
+}
+
+// Delete removes the run's group from every Pushgateway, with the same success
+// policy as Write.
+func (g *pushgatewayGroup) Delete(ctx context.Context) error {
+	var errs []error
+	for _, s := range g.sinks {
+		if err := s.Delete(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name(), err))
+		}
+	}
+	return partialFailure(errs, len(g.sinks), g.requireAll)
+}
+
+func newPushgatewaySink(url string, grouping pushGrouping, lastSuccess prometheus.Collector) *pushgatewaySink {
+	return &pushgatewaySink{
+		url:                 url,
+		job:                 grouping.job,
+		grouping:            grouping.labels,
+		lastSuccess:         lastSuccess,
+		lastSuccessGrouping: grouping.lastSuccess,
+		username:            os.Getenv("PUSHGATEWAY_USERNAME"),
+		password:            os.Getenv("PUSHGATEWAY_PASSWORD"),
+		bearerToken:         os.Getenv("PUSHGATEWAY_BEARER_TOKEN"),
+		retries:             envInt("PUSH_RETRIES", defaultPushRetries),
+		timeout:             time.Duration(envInt("PUSH_TIMEOUT_SECONDS", defaultPushTimeoutSeconds)) * time.Second,
+		add:                 os.Getenv("PUSH_MODE") == "add",
+	}
+}
+
+func (s *pushgatewaySink) Name() string { return s.url }
+
+// newPusher creates a pusher for the sink's gateway with authentication and the
+// request timeout applied; its requests are cancelled with ctx. A bearer token
+// takes precedence over basic auth.
+func (s *pushgatewaySink) newPusher(ctx context.Context) *push.Pusher {
+	client := newHTTPClient(s.timeout)
+
+	pusher := push.New(s.url, s.job)
+	switch {
+	case s.bearerToken != "":
+		if s.username != "" || s.password != "" {
+			slog.Warn("both bearer token and basic auth configured for the Pushgateway, using bearer token")
+		}
+		client.Transport = bearerTransport{token: s.bearerToken, base: client.Transport}
+	case s.username != "" || s.password != "":
+		pusher.BasicAuth(s.username, s.password)
+	}
+	return pusher.Client(contextDoer{ctx: ctx, client: client})
+}
+
+// contextDoer sends every request with ctx, as Pusher.Delete has no variant that
+// takes a context.
+type contextDoer struct {
+	ctx    context.Context
+	client push.HTTPDoer
+}
+
+func (d contextDoer) Do(req *http.Request) (*http.Response, error) {
+	return d.client.Do(req.WithContext(d.ctx))
+}
+
+func (s *pushgatewaySink) Write(ctx context.Context, collectors []prometheus.Collector) error {
+	pusher := s.newPusher(ctx)
+	for _, l := range s.grouping {
+		pusher.Grouping(l.name, l.value)
+	}
+	for _, c := range collectors {
+		pusher.Collector(c)
+	}
+	if err := s.push(ctx, pusher); err != nil {
+		return err
+	}
+
+	if s.lastSuccess == nil {
+		return nil
+	}
+	// The last-success timestamp lives in its own group without the per-run labels, so
+	// a failed run (which never pushes to this group) cannot overwrite or delete it.
+	pusher = s.newPusher(ctx)
+	for _, l := range s.lastSuccessGrouping {
+		pusher.Grouping(l.name, l.value)
+	}
+	return s.push(ctx, pusher.Collector(s.lastSuccess))
+}
+
+// Delete removes the run's group from the Pushgateway. The last-success group is
+// left untouched.
+func (s *pushgatewaySink) Delete(ctx context.Context) error {
+	pusher := s.newPusher(ctx)
+	for _, l := range s.grouping {
+		pusher.Grouping(l.name, l.value)
+	}
+	return s.retry(ctx, pusher.Delete)
+}
+
+// push pushes with retries on network errors and 5xx responses, until ctx is done.
+func (s *pushgatewaySink) push(ctx context.Context, pusher *push.Pusher) error {
+	if s.add {
+		return s.retry(ctx, func() error { return pusher.AddContext(ctx) })
+	}
+	return s.retry(ctx, func() error { return pusher.PushContext(ctx) })
+}
+
+// retry calls fn with the sink's retry policy. Once ctx is done no further attempt
+// is made.
+func (s *pushgatewaySink) retry(ctx context.Context, fn func() error) error {
+	sleep := s.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	retryable := func(err error) bool { return ctx.Err() == nil && isRetryablePushError(err) }
+	return withRetry(s.retries, sleep, retryable, fn)
+}
+
+// bearerTransport sets a bearer token Authorization header on every request.
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (t bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+t.token)
+	return base.RoundTrip(req)
+}
+
+// pushgatewayURLs builds the Pushgateway base URLs. PUSHGATEWAY_ADDRESS takes
+// precedence over PUSHGATEWAY_URL; a value that already has a scheme is used
+// verbatim, otherwise PUSHGATEWAY_SCHEME (default http) and, unless the value
+// carries its own port, PUSHGATEWAY_PORT (default 9091) are applied.
+// Either may hold a comma-separated list to push to several Pushgateways.
+func pushgatewayURLs() []string {
+	addr := os.Getenv("PUSHGATEWAY_ADDRESS")
+	if addr == "" {
+		addr = os.Getenv("PUSHGATEWAY_URL")
+	}
+	var urls []string
+	for _, a := range strings.Split(addr, ",") {
+		if strings.TrimSpace(a) != "" {
+			urls = append(urls, buildPushgatewayURL(a, os.Getenv("PUSHGATEWAY_SCHEME"), os.Getenv("PUSHGATEWAY_PORT")))
+		}
+	}
+	return urls
+}
+
+func buildPushgatewayURL(addr, scheme, port string) string {
+	addr = strings.TrimSuffix(strings.TrimSpace(addr), "/")
+	if strings.Contains(addr, "://") {
+		return addr
+	}
+	if scheme == "" {
+		scheme = "http"
+	}
+	if port == "" {
+		port = "9091"
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), port)
+	}
+	return scheme + "://" + addr
+}
+
+// textfileSink writes metrics in the text exposition format, e.g. for the
+// node_exporter textfile collector.
+type textfileSink struct {
+	path string
+}
+
+func (s textfileSink) Name() string { return "textfile" }
+
+func (s textfileSink) Write(ctx context.Context, collectors []prometheus.Collector) error {
+	registry := prometheus.NewRegistry()
+	for _, c := range collectors {
+		if err := registry.Register(c); err != nil {
+			return fmt.Errorf("registering collector: %w", err)
+		}
+	}
+	return prometheus.WriteToTextfile(s.path, registry)
+}
+
+// stdoutSink prints metrics in the text exposition format instead of sending them,
+// preceded by the grouping labels as comments. It is used for dry runs.
+type stdoutSink struct {
+	job      string
+	grouping []label
+	out      io.Writer
+}
+
+func (s stdoutSink) Name() string { return "stdout" }
+
+func (s stdoutSink) Write(ctx context.Context, collectors []prometheus.Collector) error {
+	families, err := gather(collectors)
+	if err != nil {
+		return err
+	}
+	out := s.out
+	if out == nil {
+		out = os.Stdout
+	}
+
+	fmt.Fprintf(out, "# Dry run: metrics would be pushed for job %q with grouping labels:\n", s.job)
+	for _, l := range s.grouping {
+		fmt.Fprintf(out, "#   %s=%q\n", l.name, l.value)
+	}
+	for _, mf := range families {
+		if _, err := expfmt.MetricFamilyToText(out, mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonSink writes every series and the grouping labels as a single JSON object,
+// for pipelines that post-process the metrics themselves. Series are keyed like
+// the text format, e.g. `terraform_to_add` or `terraform_error_category{category="auth"}`.
+type jsonSink struct {
+	path     string
+	job      string
+	grouping []label
+}
+
+func (s jsonSink) Name() string { return "json" }
+
+func (s jsonSink) Write(ctx context.Context, collectors []prometheus.Collector) error {
+	families, err := gather(collectors)
+	if err != nil {
+		return err
+	}
+
+	grouping := map[string]string{}
+	for _, l := range s.grouping {
+		grouping[l.name] = l.value
+	}
+	metrics := map[string]float64{}
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			key := seriesKey(mf.GetName(), m.GetLabel())
+			switch {
+			case m.GetGauge() != nil:
+				metrics[key] = m.GetGauge().GetValue()
+			case m.GetCounter() != nil:
+				metrics[key] = m.GetCounter().GetValue()
+			case m.GetUntyped() != nil:
+				metrics[key] = m.GetUntyped().GetValue()
+			case m.GetHistogram() != nil:
+				metrics[seriesKey(mf.GetName()+"_sum", m.GetLabel())] = m.GetHistogram().GetSampleSum()
+				metrics[seriesKey(mf.GetName()+"_count", m.GetLabel())] = float64(m.GetHistogram().GetSampleCount())
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(struct {
+		Job      string             `json:"job"`
+		Grouping map[string]string  `json:"grouping"`
+		Metrics  map[string]float64 `json:"metrics"`
+	}{s.job, grouping, metrics}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, append(data, '\n'), 0644)
+}
+
+// seriesKey formats a series as name{label="value",...}, or just name without labels.
+func seriesKey(name string, labels []*dto.LabelPair) string {
+	if len(labels) == 0 {
+		return name
+	}
+	pairs := make([]string, 0, len(labels))
+	for _, l := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", l.GetName(), l.GetValue()))
+	}
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+// gather collects the current values of collectors, sorted by metric name.
+func gather(collectors []prometheus.Collector) ([]*dto.MetricFamily, error) {
+	registry := prometheus.NewRegistry()
+	for _, c := range collectors {
+		if err := registry.Register(c); err != nil {
+			return nil, fmt.Errorf("registering collector: %w", err)
+		}
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return nil, fmt.Errorf("gathering metrics: %w", err)
+	}
+	return families, nil
+}
+
+// writeToSinks writes to every sink and aggregates their errors. Unless requireAll
+// is set, the write only fails when no sink succeeded.
+func writeToSinks(ctx context.Context, sinks []MetricSink, collectors []prometheus.Collector, requireAll bool) error {
+	var errs []error
+	for _, sink := range sinks {
+		if err := sink.Write(ctx, collectors); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return partialFailure(errs, len(sinks), requireAll)
+}
+
+// partialFailure joins the errors of attempted writes. Unless requireAll is set, it
+// only returns an error when every attempt failed and merely logs partial failures.
+func partialFailure(errs []error, attempted int, requireAll bool) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	if requireAll || len(errs) == attempted {
+		return errors.Join(errs...)
+	}
+	slog.Warn("some outputs failed", "error", errors.Join(errs...))
+	return nil
+}
@@ -0,0 +1,324 @@
+package exporter
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// recordedRequest is a request seen by a test Pushgateway.
+type recordedRequest struct {
+	method, path string
+}
+
+// recordingPushgateway records every request. It answers like a Pushgateway, 200
+// for pushes and 202 for deletes, or with failStatus when that is set.
+type recordingPushgateway struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+func newRecordingPushgateway(failStatus int) *recordingPushgateway {
+	gw := &recordingPushgateway{}
+	gw.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gw.mu.Lock()
+		gw.requests = append(gw.requests, recordedRequest{r.Method, r.URL.EscapedPath()})
+		gw.mu.Unlock()
+		switch {
+		case failStatus != 0:
+			w.WriteHeader(failStatus)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	return gw
+}
+
+// checkRequest fails the test unless req used method on the group of job
+// "terraform" and instance "42". Grouping labels come in no particular order.
+func checkRequest(t *testing.T, req recordedRequest, method string) {
+	t.Helper()
+	if req.method != method || !strings.HasPrefix(req.path, "/metrics/job/terraform/") || !strings.Contains(req.path, "/instance/42") {
+		t.Errorf("request = %s %s, want %s on the terraform job's instance 42 group", req.method, req.path, method)
+	}
+}
+
+func (gw *recordingPushgateway) Requests() []recordedRequest {
+	gw.mu.Lock()
+	defer gw.mu.Unlock()
+	return append([]recordedRequest(nil), gw.requests...)
+}
+
+// setPushgatewayEnv points the exporter at the given Pushgateway URLs with a fixed
+// job and instance.
+func setPushgatewayEnv(t *testing.T, urls ...string) {
+	t.Helper()
+	clearEnv(t)
+	for _, name := range []string{"PUSH_MODE", "PUSH_REQUIRE_ALL", "COMMIT_MESSAGE", "GITHUB_WORKFLOW", "GIT_BRANCH", "GITHUB_HEAD_REF", "GITHUB_REF_NAME", "PR_NUMBER", "EXTRA_GROUPING_LABELS", "PUSHGATEWAY_USERNAME", "PUSHGATEWAY_PASSWORD", "PUSHGATEWAY_BEARER_TOKEN"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("PUSHGATEWAY_URL", strings.Join(urls, ","))
+	t.Setenv("PUSHGATEWAY_JOB", "terraform")
+	t.Setenv("GITHUB_RUN_ID", "42")
+	t.Setenv("PUSH_RETRIES", "1")
+}
+
+func TestPushModes(t *testing.T) {
+	tests := []struct {
+		mode, method string
+	}{
+		{"", http.MethodPut},
+		{"push", http.MethodPut},
+		{"add", http.MethodPost},
+	}
+	for _, tt := range tests {
+		t.Run("mode="+tt.mode, func(t *testing.T) {
+			gw := newRecordingPushgateway(0)
+			defer gw.Close()
+			setPushgatewayEnv(t, gw.URL)
+			t.Setenv("PUSH_MODE", tt.mode)
+
+			sinks := newPushgatewaySinks(groupingFromEnv(), nil)
+			if err := sinks.Write(context.Background(), []prometheus.Collector{testGauge()}); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			got := gw.Requests()
+			if len(got) != 1 {
+				t.Fatalf("requests = %v, want one", got)
+			}
+			checkRequest(t, got[0], tt.method)
+		})
+	}
+}
+
+func TestPushModeDelete(t *testing.T) {
+	gw := newRecordingPushgateway(0)
+	defer gw.Close()
+	setPushgatewayEnv(t, gw.URL)
+	t.Setenv("PUSH_MODE", "delete")
+
+	if err := DeleteMetrics(); err != nil {
+		t.Fatalf("DeleteMetrics: %v", err)
+	}
+	got := gw.Requests()
+	if len(got) != 1 {
+		t.Fatalf("requests = %v, want one", got)
+	}
+	checkRequest(t, got[0], http.MethodDelete)
+}
+
+func TestDeleteTargetsPushedGroup(t *testing.T) {
+	gw := newRecordingPushgateway(0)
+	defer gw.Close()
+	setPushgatewayEnv(t, gw.URL)
+	t.Setenv("COMMIT_MESSAGE", "Fix/cleanup")
+	t.Setenv("GIT_BRANCH", "main")
+
+	if err := newPushgatewaySinks(groupingFromEnv(), nil).Write(context.Background(), []prometheus.Collector{testGauge()}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := DeleteMetrics(); err != nil {
+		t.Fatalf("DeleteMetrics: %v", err)
+	}
+	got := gw.Requests()
+	if len(got) != 2 {
+		t.Fatalf("requests = %v, want a push and a delete", got)
+	}
+	if push, del := groupSegments(got[0].path), groupSegments(got[1].path); !reflect.DeepEqual(push, del) {
+		t.Errorf("delete group %v differs from pushed group %v", del, push)
+	}
+}
+
+func TestGroupingOmitsEmptyValues(t *testing.T) {
+	gw := newRecordingPushgateway(0)
+	defer gw.Close()
+	setPushgatewayEnv(t, gw.URL)
+	// Blank after sanitizing, like the unset workflow, PR number and extra labels
+	t.Setenv("COMMIT_MESSAGE", " \n ")
+	t.Setenv("GIT_BRANCH", "main")
+	t.Setenv("EXTRA_GROUPING_LABELS", "env=")
+
+	if err := newPushgatewaySinks(groupingFromEnv(), nil).Write(context.Background(), []prometheus.Collector{testGauge()}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	got := gw.Requests()
+	if len(got) != 1 {
+		t.Fatalf("requests = %v, want one push", got)
+	}
+	want := []string{"branch=main", "instance=42", "job=terraform", "job=terraform"}
+	if segments := groupSegments(got[0].path); !reflect.DeepEqual(segments, want) {
+		t.Errorf("pushed to %s, want only the grouping labels %v", got[0].path, want)
+	}
+}
+
+// groupSegments returns the label/value pairs of a Pushgateway path, sorted.
+func groupSegments(path string) []string {
+	parts := strings.Split(strings.TrimPrefix(path, "/metrics/"), "/")
+	var pairs []string
+	for i := 0; i+1 < len(parts); i += 2 {
+		pairs = append(pairs, parts[i]+"="+parts[i+1])
+	}
+	sort.Strings(pairs)
+	return pairs
+}
+
+func TestPushModeDeleteFailure(t *testing.T) {
+	gw := newRecordingPushgateway(http.StatusInternalServerError)
+	defer gw.Close()
+	setPushgatewayEnv(t, gw.URL)
+
+	if err := DeleteMetrics(); !errors.Is(err, ErrPushFailed) {
+		t.Fatalf("err = %v, want ErrPushFailed", err)
+	}
+}
+
+func TestMultiplePushgateways(t *testing.T) {
+	tests := []struct {
+		name       string
+		requireAll string
+		failing    int
+		wantErr    bool
+	}{
+		{"one failing", "", 1, false},
+		{"one failing, all required", "true", 1, true},
+		{"all failing", "", 2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gateways []*recordingPushgateway
+			var urls []string
+			for i := 0; i < 2; i++ {
+				status := 0
+				if i < tt.failing {
+					status = http.StatusInternalServerError
+				}
+				gw := newRecordingPushgateway(status)
+				defer gw.Close()
+				gateways = append(gateways, gw)
+				urls = append(urls, gw.URL)
+			}
+			setPushgatewayEnv(t, urls...)
+			t.Setenv("PUSH_REQUIRE_ALL", tt.requireAll)
+
+			_, err := CollectAndPush(Config{Outputs: []string{"pushgateway"}})
+			if tt.wantErr && !errors.Is(err, ErrPushFailed) {
+				t.Errorf("err = %v, want ErrPushFailed", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("CollectAndPush: %v", err)
+			}
+			// A failing gateway never keeps the others from being pushed to
+			for i, gw := range gateways {
+				got := gw.Requests()
+				if len(got) == 0 {
+					t.Fatalf("gateway %d got no push", i)
+				}
+				checkRequest(t, got[0], http.MethodPut)
+			}
+		})
+	}
+}
+
+func TestPushgatewayAuthorization(t *testing.T) {
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("ci:s3cret"))
+	tests := []struct {
+		name, username, password, token, want string
+	}{
+		{"none", "", "", "", ""},
+		{"basic auth", "ci", "s3cret", "", basic},
+		{"bearer token", "", "", "tok123", "Bearer tok123"},
+		{"bearer token wins", "ci", "s3cret", "tok123", "Bearer tok123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var headers []string
+			gw := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				headers = append(headers, r.Header.Get("Authorization"))
+				mu.Unlock()
+			}))
+			defer gw.Close()
+			setPushgatewayEnv(t, gw.URL)
+			t.Setenv("PUSHGATEWAY_USERNAME", tt.username)
+			t.Setenv("PUSHGATEWAY_PASSWORD", tt.password)
+			t.Setenv("PUSHGATEWAY_BEARER_TOKEN", tt.token)
+
+			if err := newPushgatewaySinks(groupingFromEnv(), testGauge()).Write(context.Background(), []prometheus.Collector{testGauge()}); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			// Both the run's group and the last-success group are authenticated
+			if len(headers) != 2 {
+				t.Fatalf("got %d requests, want 2", len(headers))
+			}
+			for _, got := range headers {
+				if got != tt.want {
+					t.Errorf("Authorization = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestOutputJSONPath(t *testing.T) {
+	setPushgatewayEnv(t, "http://pushgateway.invalid:9091")
+	for _, name := range []string{"EXTRA_METRICS_FILE", "PUSH_STATE_FILE", "METRICS_INCLUDE", "METRICS_EXCLUDE", "DURATION_BUCKETS"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("DURATION_HISTOGRAM", "true")
+	path := filepath.Join(t.TempDir(), "metrics.json")
+	t.Setenv("OUTPUT_JSON_PATH", path)
+
+	cfg := Config{
+		PlanPath:     writeTestFile(t, "plan.json", replacePlanJSON),
+		ApplyLogPath: writeTestFile(t, "apply.log", replaceApplyLog),
+		DryRun:       true,
+	}
+	if _, err := CollectAndPush(cfg); err != nil {
+		t.Fatalf("CollectAndPush: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump struct {
+		Job      string             `json:"job"`
+		Grouping map[string]string  `json:"grouping"`
+		Metrics  map[string]float64 `json:"metrics"`
+	}
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatalf("parsing %s: %v", data, err)
+	}
+
+	if want := map[string]string{"instance": "42", "job": "terraform"}; dump.Job != "terraform" || !reflect.DeepEqual(dump.Grouping, want) {
+		t.Errorf("job = %q, grouping = %v, want terraform and %v", dump.Job, dump.Grouping, want)
+	}
+	for key, want := range map[string]float64{
+		"terraform_to_add":                     1,
+		"terraform_to_replace":                 2,
+		"terraform_result":                     1,
+		`terraform_run_type{run_type="apply"}`: 1,
+		`terraform_resource_changes{action="create",type="aws_s3_bucket"}`: 1,
+		"terraform_last_success_timestamp":                                 dump.Metrics["terraform_timestamp"],
+		"terraform_execution_duration_count":                               1,
+	} {
+		if got, ok := dump.Metrics[key]; !ok || got != want {
+			t.Errorf("metrics[%s] = %v (present %v), want %v", key, got, ok, want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+)
+
+// Slack rejects section texts longer than this.
+const slackMaxSectionText = 3000
+
+// slackNotifier posts the run summary to a Slack incoming webhook.
+type slackNotifier struct {
+	webhookURL string
+	// notifyOn is "always" (default), "failure" or "changes".
+	notifyOn string
+	client   *http.Client
+}
+
+func newSlackNotifier() *slackNotifier {
+	return &slackNotifier{
+		webhookURL: os.Getenv("SLACK_WEBHOOK_URL"),
+		notifyOn:   os.Getenv("SLACK_NOTIFY_ON"),
+	}
+}
+
+// hasChanges reports whether the run planned or applied any resource changes.
+func hasChanges(m Metrics) bool {
+	return m.ToAdd+m.ToChange+m.ToDestroy+m.ToImport+m.ToReplace > 0 ||
+		m.Added+m.Changed+m.Destroyed+m.Imported > 0
+}
+
+// shouldNotify applies the SLACK_NOTIFY_ON filter to the run.
+func (n *slackNotifier) shouldNotify(m Metrics) bool {
+	switch n.notifyOn {
+	case "failure":
+		return !m.Succeeded
+	case "changes":
+		return hasChanges(m)
+	default:
+		return true
+	}
+}
+
+// slackMessage builds the webhook payload: a header with the run ID, workflow and
+// result, followed by the summary.
+func slackMessage(runID, workflow string, succeeded bool, summary string) map[string]interface{} {
+	result := "✅ succeeded"
+	if !succeeded {
+		result = "❌ failed"
+	}
+	header := fmt.Sprintf("Terraform run %s %s", runID, result)
+	if workflow != "" {
+		header = fmt.Sprintf("%s: Terraform run %s %s", workflow, runID, result)
+	}
+	if len(summary) > slackMaxSectionText {
+		summary = truncateLabelValue(summary, slackMaxSectionText)
+	}
+
+	blocks := []map[string]interface{}{
+		{"type": "header", "text": map[string]string{"type": "plain_text", "text": header}},
+	}
+	if summary != "" {
+		blocks = append(blocks, map[string]interface{}{
+			"type": "section", "text": map[string]string{"type": "mrkdwn", "text": summary},
+		})
+	}
+	return map[string]interface{}{"text": header, "blocks": blocks}
+}
+
+// Notify posts the summary unless SLACK_NOTIFY_ON filters the run out.
+func (n *slackNotifier) Notify(runID string, m Metrics, summary string) error {
+	if !n.shouldNotify(m) {
+		return nil
+	}
+	body, err := json.Marshal(slackMessage(runID, os.Getenv("GITHUB_WORKFLOW"), m.Succeeded, summary))
+	if err != nil {
+		return err
+	}
+
+	client := n.client
+	if client == nil {
+		client = newHTTPClient(0)
+	}
+	resp, err := client.Post(n.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("posting to slack: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("slack webhook returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// NotifySlack sends the summary when SLACK_WEBHOOK_URL is set, except in a dry run.
+// Failures are only logged; they never fail the run.
+func NotifySlack(runID string, m Metrics, summary string) {
+	n := newSlackNotifier()
+	if n.webhookURL == "" || dryRunEnabled() {
+		return
+	}
+	if err := n.Notify(runID, m, summary); err != nil {
+		slog.Warn("Slack notification failed", "error", err)
+	}
+}
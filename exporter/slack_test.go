@@ -0,0 +1,85 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestNotifySlackPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		notifyOn string
+		m        Metrics
+		want     map[string]interface{}
+	}{
+		{
+			name: "success",
+			m:    Metrics{Succeeded: true, ToAdd: 1},
+			want: map[string]interface{}{
+				"text": "deploy: Terraform run 42 ✅ succeeded",
+				"blocks": []interface{}{
+					map[string]interface{}{"type": "header", "text": map[string]interface{}{"type": "plain_text", "text": "deploy: Terraform run 42 ✅ succeeded"}},
+					map[string]interface{}{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": "Adds one bucket."}},
+				},
+			},
+		},
+		{
+			name: "failure",
+			m:    Metrics{},
+			want: map[string]interface{}{
+				"text": "deploy: Terraform run 42 ❌ failed",
+				"blocks": []interface{}{
+					map[string]interface{}{"type": "header", "text": map[string]interface{}{"type": "plain_text", "text": "deploy: Terraform run 42 ❌ failed"}},
+					map[string]interface{}{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": "Adds one bucket."}},
+				},
+			},
+		},
+		{name: "filtered out by SLACK_NOTIFY_ON", notifyOn: "failure", m: Metrics{Succeeded: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var payloads []map[string]interface{}
+			webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("Content-Type = %q, want application/json", ct)
+				}
+				var payload map[string]interface{}
+				if err := json.Unmarshal(body, &payload); err != nil {
+					t.Errorf("payload %s: %v", body, err)
+				}
+				mu.Lock()
+				payloads = append(payloads, payload)
+				mu.Unlock()
+			}))
+			defer webhook.Close()
+			t.Setenv("SLACK_WEBHOOK_URL", webhook.URL)
+			t.Setenv("SLACK_NOTIFY_ON", tt.notifyOn)
+			t.Setenv("GITHUB_WORKFLOW", "deploy")
+			t.Setenv("DRY_RUN", "")
+
+			NotifySlack("42", tt.m, "Adds one bucket.")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if tt.want == nil {
+				if len(payloads) != 0 {
+					t.Errorf("sent %d notifications, want none", len(payloads))
+				}
+				return
+			}
+			if len(payloads) != 1 {
+				t.Fatalf("sent %d notifications, want 1", len(payloads))
+			}
+			if !reflect.DeepEqual(payloads[0], tt.want) {
+				t.Errorf("payload = %v, want %v", payloads[0], tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// stateModule is a module in `terraform show -json` output of the state.
+type stateModule struct {
+	Resources []struct {
+		Mode string `json:"mode"`
+		Type string `json:"type"`
+	} `json:"resources"`
+	ChildModules []stateModule `json:"child_modules"`
+}
+
+type stateJSON struct {
+	Values *struct {
+		RootModule stateModule `json:"root_module"`
+	} `json:"values"`
+}
+
+// parseStateResources counts the managed resources per type in the root module and
+// all nested child modules of a `terraform show -json` state. Data sources are
+// not counted.
+func parseStateResources(path string) (map[string]int, error) {
+	data, err := readLog(path)
+	if err != nil {
+		return nil, err
+	}
+	var state stateJSON
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parsing state JSON: %w", err)
+	}
+
+	counts := map[string]int{}
+	if state.Values != nil {
+		countStateModule(state.Values.RootModule, counts)
+	}
+	return counts, nil
+}
+
+func countStateModule(module stateModule, counts map[string]int) {
+	for _, r := range module.Resources {
+		if r.Mode == "managed" {
+			counts[r.Type]++
+		}
+	}
+	for _, child := range module.ChildModules {
+		countStateModule(child, counts)
+	}
+}
@@ -0,0 +1,59 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+// nestedStateJSON is trimmed `terraform show -json` output of a state with a module
+// nested two levels deep.
+const nestedStateJSON = `{
+  "format_version": "1.0",
+  "terraform_version": "1.9.5",
+  "values": {
+    "root_module": {
+      "resources": [
+        {"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "name": "logs"},
+        {"address": "data.aws_caller_identity.current", "mode": "data", "type": "aws_caller_identity", "name": "current"}
+      ],
+      "child_modules": [
+        {
+          "address": "module.web",
+          "resources": [
+            {"address": "module.web.aws_instance.this[0]", "mode": "managed", "type": "aws_instance", "name": "this", "index": 0},
+            {"address": "module.web.aws_instance.this[1]", "mode": "managed", "type": "aws_instance", "name": "this", "index": 1}
+          ],
+          "child_modules": [
+            {
+              "address": "module.web.module.logs",
+              "resources": [
+                {"address": "module.web.module.logs.aws_s3_bucket.this", "mode": "managed", "type": "aws_s3_bucket", "name": "this"},
+                {"address": "module.web.module.logs.data.aws_iam_policy_document.this", "mode": "data", "type": "aws_iam_policy_document", "name": "this"}
+              ]
+            }
+          ]
+        }
+      ]
+    }
+  }
+}`
+
+func TestParseStateResources(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("EXTRA_METRICS_FILE", "")
+	t.Setenv("TERRAFORM_STATE_JSON_PATH", writeTestFile(t, "state.json", nestedStateJSON))
+
+	m := parseMetrics(runLogs{})
+	want := map[string]int{"aws_s3_bucket": 2, "aws_instance": 2}
+	if !reflect.DeepEqual(m.StateResourcesByType, want) {
+		t.Errorf("StateResourcesByType = %v, want %v", m.StateResourcesByType, want)
+	}
+	values := gatherValues(t, buildCollectors(m, newMetricRegistry(nil, false)))
+	if got := values["terraform_state_resources_total"]; len(got) != 1 || got[0] != 4 {
+		t.Errorf("terraform_state_resources_total = %v, want [4]", got)
+	}
+	// Sorted by the type label: aws_instance, aws_s3_bucket
+	if got, want := values["terraform_state_resources"], []float64{2, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("terraform_state_resources = %v, want %v", got, want)
+	}
+}
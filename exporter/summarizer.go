@@ -0,0 +1,261 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Summarizer turns a prompt containing the Terraform logs into a human-readable summary.
+type Summarizer interface {
+	Summarize(ctx context.Context, prompt string) (string, error)
+}
+
+// tokenReporter is implemented by summarizers that know how many tokens the last
+// Summarize call used.
+type tokenReporter interface {
+	tokensUsed() int
+}
+
+// errSummarizerUnavailable is returned when the selected provider has no credentials
+// or endpoint configured; a basic summary is written instead.
+var errSummarizerUnavailable = errors.New("summarizer not configured")
+
+const defaultSummaryTimeoutSeconds = 60
+
+// ErrSummaryTimeout is returned when the summary request exceeded its deadline, so
+// callers can tell a hung LLM call apart from other failures.
+var ErrSummaryTimeout = errors.New("summary request timed out")
+
+// summarizeWithTimeout bounds a Summarize call by timeout.
+func summarizeWithTimeout(ctx context.Context, summarizer Summarizer, prompt string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	text, err := summarizer.Summarize(ctx, prompt)
+	if err != nil && (errors.Is(err, context.DeadlineExceeded) || ctx.Err() == context.DeadlineExceeded) {
+		return "", fmt.Errorf("%w after %s: %v", ErrSummaryTimeout, timeout, err)
+	}
+	return text, err
+}
+
+// newSummarizer returns the summarizer selected by SUMMARY_PROVIDER (default gemini).
+func newSummarizer(ctx context.Context) (Summarizer, error) {
+	switch provider := os.Getenv("SUMMARY_PROVIDER"); provider {
+	case "", "gemini":
+		g, err := newGeminiSummarizer(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return g, nil
+	case "openai":
+		o, err := newOpenAISummarizer()
+		if err != nil {
+			return nil, err
+		}
+		return o, nil
+	default:
+		return nil, fmt.Errorf("unknown SUMMARY_PROVIDER %q", provider)
+	}
+}
+
+const defaultPromptInstructions = `Generate a human-readable summary of:
+1. What was changed (added, updated, deleted)?
+2. Any errors or warnings?
+3. Overall outcome (success, failed, partial)?
+4. Highlight risky or unusual changes.
+Keep it under %d words.`
+
+const defaultSummaryWordLimit = 250
+
+// buildPrompt combines the concatenated logs with the built-in instructions, or
+// renders the text/template in promptFile with the logs available as {{.Logs}}
+// and the word limit as {{.WordLimit}}.
+func buildPrompt(logText, promptFile string, wordLimit int) (string, error) {
+	var builder strings.Builder
+	if promptFile == "" {
+		builder.WriteString(logText)
+		builder.WriteString(fmt.Sprintf(defaultPromptInstructions, wordLimit))
+	} else {
+		raw, err := os.ReadFile(promptFile)
+		if err != nil {
+			return "", fmt.Errorf("reading prompt file %s: %w", promptFile, err)
+		}
+		tmpl, err := template.New("prompt").Parse(string(raw))
+		if err != nil {
+			return "", fmt.Errorf("parsing prompt file %s: %w", promptFile, err)
+		}
+		if err := tmpl.Execute(&builder, struct {
+			Logs      string
+			WordLimit int
+		}{logText, wordLimit}); err != nil {
+			return "", fmt.Errorf("rendering prompt file %s: %w", promptFile, err)
+		}
+	}
+	if lang := os.Getenv("SUMMARY_LANGUAGE"); lang != "" && !strings.EqualFold(lang, "english") {
+		builder.WriteString(fmt.Sprintf("\nRespond in %s.", lang))
+	}
+	return builder.String(), nil
+}
+
+// SummaryStats describes one summarization, for the combined run mode's metrics.
+type SummaryStats struct {
+	// text is the summary that was written, AI-generated or basic
+	text     string
+	ai       bool
+	tokens   int
+	duration time.Duration
+}
+
+// Text returns the summary that was written, or "" when none was.
+func (s SummaryStats) Text() string { return s.text }
+
+func summarize(runID string, runLogs runLogs) (stats SummaryStats, err error) {
+	if os.Getenv("SUMMARY_ENABLED") == "false" {
+		slog.Debug("summary disabled by SUMMARY_ENABLED")
+		return stats, nil
+	}
+
+	start := time.Now()
+	defer func() { stats.duration = time.Since(start) }()
+
+	logs := map[string]string{
+		"refresh": runLogs.refreshLog,
+		"plan":    runLogs.planLog,
+		"apply":   runLogs.applyLog,
+	}
+	outputPath := inLogDir(fmt.Sprintf("terraform-gemini-summary-%s.log", runID))
+
+	// The template provider works offline from the parsed metrics, not the raw logs
+	if os.Getenv("SUMMARY_PROVIDER") == "template" {
+		text, err := templateSummary(runID, parseMetrics(runLogs), os.Getenv("SUMMARY_TEMPLATE_FILE"))
+		if err != nil {
+			return stats, err
+		}
+		stats.text = text
+		return stats, writeSummary(runID, outputPath, text)
+	}
+
+	ctx := context.Background()
+	summarizer, err := newSummarizer(ctx)
+	if errors.Is(err, errSummarizerUnavailable) {
+		slog.Warn("AI summary unavailable, writing basic summary instead", "reason", err)
+		stats.text = basicSummary(logs)
+		return stats, writeSummary(runID, outputPath, stats.text)
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	var builder strings.Builder
+	builder.WriteString("Here are three (two if apply is not present) logs from a Terraform execution:\n---\n")
+	for label, name := range logs {
+		path := name
+		data, err := readLog(path)
+		if errors.Is(err, os.ErrNotExist) {
+			slog.Info("log file not found, skipping", "path", path)
+			continue
+		}
+		if err != nil {
+			return stats, fmt.Errorf("reading log file %s: %w", path, err)
+		}
+		builder.WriteString(fmt.Sprintf("📄 %s Log:\n%s\n\n", strings.ToUpper(label), data))
+	}
+
+	wordLimit := envInt("GEMINI_SUMMARY_WORD_LIMIT", defaultSummaryWordLimit)
+	// Logs leave the machine from here on, so mask anything that looks like a secret
+	logText := redactSecrets(builder.String(), loadRedactPatterns())
+	prompt, err := buildPrompt(logText, os.Getenv("GEMINI_PROMPT_FILE"), wordLimit)
+	if err != nil {
+		return stats, err
+	}
+
+	text, err := summarizeWithTimeout(ctx, summarizer, prompt, time.Duration(envInt("GEMINI_TIMEOUT_SECONDS", defaultSummaryTimeoutSeconds))*time.Second)
+	if err != nil {
+		return stats, err
+	}
+	stats.ai = true
+	if tr, ok := summarizer.(tokenReporter); ok {
+		stats.tokens = tr.tokensUsed()
+	}
+
+	if len(text) == 0 {
+		return stats, fmt.Errorf("no content returned from summarizer")
+	}
+	if words := len(strings.Fields(text)); words > wordLimit {
+		slog.Warn("summary exceeds the requested word limit", "words", words, "limit", wordLimit)
+	}
+
+	stats.text = text
+	return stats, writeSummary(runID, outputPath, text)
+}
+
+func writeSummary(runID, outputPath, text string) error {
+	if err := os.WriteFile(outputPath, []byte(text), 0644); err != nil {
+		return fmt.Errorf("writing summary to file: %w", err)
+	}
+
+	slog.Info("summary written", "path", outputPath)
+
+	if stepSummary := os.Getenv("GITHUB_STEP_SUMMARY"); stepSummary != "" {
+		if err := appendStepSummary(stepSummary, runID, text); err != nil {
+			slog.Warn("could not write GitHub step summary", "error", err)
+		}
+	}
+	return nil
+}
+
+// appendStepSummary appends the summary as markdown to the GitHub Actions step
+// summary file so it shows up in the run's summary page.
+func appendStepSummary(path, runID, text string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "## Terraform summary (run %s)\n\n%s\n", runID, text)
+	return err
+}
+
+// basicSummary builds a deterministic, non-AI summary from the same logs that
+// would otherwise be sent to the LLM.
+func basicSummary(logs map[string]string) string {
+	var builder strings.Builder
+	builder.WriteString("AI summary unavailable. Basic summary:\n\n")
+
+	applyPath := logs["apply"]
+	if logExists(applyPath) {
+		added, changed, destroyed, imported := parseLogStats(applyPath)
+		builder.WriteString(fmt.Sprintf("Changes: %d added, %d changed, %d destroyed, %d imported.\n", added, changed, destroyed, imported))
+	} else {
+		applyPath = logs["plan"]
+		builder.WriteString("Changes: no apply log found, plan-only run.\n")
+	}
+
+	errorCount := 0
+	for _, label := range []string{"refresh", "plan", "apply"} {
+		data, err := readLog(logs[label])
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(strings.ToLower(string(data)), "\n") {
+			if strings.Contains(line, "error") {
+				errorCount++
+			}
+		}
+	}
+	builder.WriteString(fmt.Sprintf("Errors found: %d line(s) mentioning an error.\n", errorCount))
+
+	outcome := "failed"
+	if isTerraformRunSuccessful(applyPath) {
+		outcome = "success"
+	}
+	builder.WriteString(fmt.Sprintf("Outcome: %s.\n", outcome))
+	return builder.String()
+}
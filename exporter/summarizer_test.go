@@ -0,0 +1,232 @@
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingSummarizer never answers; it returns once its context is done.
+type blockingSummarizer struct{}
+
+func (blockingSummarizer) Summarize(ctx context.Context, prompt string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+// staticSummarizer answers at once.
+type staticSummarizer string
+
+func (s staticSummarizer) Summarize(ctx context.Context, prompt string) (string, error) {
+	return string(s), nil
+}
+
+func TestSummarizeWithTimeout(t *testing.T) {
+	start := time.Now()
+	_, err := summarizeWithTimeout(context.Background(), blockingSummarizer{}, "prompt", 50*time.Millisecond)
+	if !errors.Is(err, ErrSummaryTimeout) {
+		t.Fatalf("err = %v, want ErrSummaryTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("timed out after %s, want about 50ms", elapsed)
+	}
+}
+
+func TestSummarizeWithinTimeout(t *testing.T) {
+	text, err := summarizeWithTimeout(context.Background(), staticSummarizer("all good"), "prompt", time.Second)
+	if err != nil || text != "all good" {
+		t.Errorf("summarizeWithTimeout = %q, %v; want the summary", text, err)
+	}
+}
+
+func TestSummarizeCancelledIsNotTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := summarizeWithTimeout(ctx, blockingSummarizer{}, "prompt", time.Minute)
+	if errors.Is(err, ErrSummaryTimeout) || !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
+
+func TestGeminiTimeoutSeconds(t *testing.T) {
+	release := make(chan struct{})
+	llm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer llm.Close()
+	defer close(release)
+
+	dir := t.TempDir()
+	t.Setenv("TERRAFORM_LOG_DIR", dir)
+	t.Setenv("SUMMARY_ENABLED", "")
+	t.Setenv("SUMMARY_PROVIDER", "openai")
+	t.Setenv("LLM_BASE_URL", llm.URL)
+	t.Setenv("LLM_API_KEY", "key")
+	t.Setenv("GEMINI_PROMPT_FILE", "")
+	t.Setenv("GEMINI_TIMEOUT_SECONDS", "1")
+
+	start := time.Now()
+	_, err := summarize("1", runLogs{planLog: writeTestFile(t, "plan.log", "Plan: 1 to add, 0 to change, 0 to destroy.\n")})
+	if !errors.Is(err, ErrSummaryTimeout) {
+		t.Fatalf("err = %v, want ErrSummaryTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second || elapsed > 10*time.Second {
+		t.Errorf("summary gave up after %s, want about GEMINI_TIMEOUT_SECONDS=1", elapsed)
+	}
+}
+
+// promptRecorder is a chat-completions API that records the prompts it receives
+// and answers each with a fixed summary.
+type promptRecorder struct {
+	*httptest.Server
+	mu      sync.Mutex
+	prompts []string
+}
+
+func newPromptRecorder() *promptRecorder {
+	rec := &promptRecorder{}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req chatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rec.mu.Lock()
+		for _, msg := range req.Messages {
+			rec.prompts = append(rec.prompts, msg.Content)
+		}
+		rec.mu.Unlock()
+		fmt.Fprint(w, `{"choices": [{"message": {"role": "assistant", "content": "1 resource added"}}], "usage": {"total_tokens": 12}}`)
+	}))
+	return rec
+}
+
+// Prompt returns the last prompt received.
+func (rec *promptRecorder) Prompt() string {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.prompts) == 0 {
+		return ""
+	}
+	return rec.prompts[len(rec.prompts)-1]
+}
+
+// setPromptRecorderEnv selects the openai provider pointed at rec.
+func setPromptRecorderEnv(t *testing.T, rec *promptRecorder) {
+	t.Helper()
+	t.Setenv("TERRAFORM_LOG_DIR", t.TempDir())
+	for _, name := range []string{"SUMMARY_ENABLED", "GEMINI_PROMPT_FILE", "REDACT_PATTERNS_FILE", "GITHUB_STEP_SUMMARY", "SUMMARY_LANGUAGE"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("SUMMARY_PROVIDER", "openai")
+	t.Setenv("LLM_BASE_URL", rec.URL)
+	t.Setenv("LLM_API_KEY", "key")
+}
+
+func TestGitHubStepSummary(t *testing.T) {
+	rec := newPromptRecorder()
+	defer rec.Close()
+	setPromptRecorderEnv(t, rec)
+	stepSummary := writeTestFile(t, "step_summary.md", "## Tests\n\nAll passed\n")
+	t.Setenv("GITHUB_STEP_SUMMARY", stepSummary)
+
+	if _, err := summarize("42", runLogs{planLog: writeTestFile(t, "plan.log", "Plan: 1 to add, 0 to change, 0 to destroy.\n")}); err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	got, err := os.ReadFile(stepSummary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "## Tests\n\nAll passed\n## Terraform summary (run 42)\n\n1 resource added\n"; string(got) != want {
+		t.Errorf("step summary = %q, want %q", got, want)
+	}
+}
+
+func TestGitHubStepSummaryUnwritable(t *testing.T) {
+	rec := newPromptRecorder()
+	defer rec.Close()
+	setPromptRecorderEnv(t, rec)
+	t.Setenv("GITHUB_STEP_SUMMARY", filepath.Join(t.TempDir(), "missing", "step_summary.md"))
+
+	if _, err := summarize("42", runLogs{planLog: writeTestFile(t, "plan.log", "Plan: 1 to add, 0 to change, 0 to destroy.\n")}); err != nil {
+		t.Errorf("summarize: %v, want the unwritable step summary only logged", err)
+	}
+}
+
+func TestSummaryWordLimitInPrompt(t *testing.T) {
+	tests := []struct {
+		name, limit, promptFile string
+		want                    string
+	}{
+		{"default", "", "", "Keep it under 250 words."},
+		{"GEMINI_SUMMARY_WORD_LIMIT", "400", "", "Keep it under 400 words."},
+		{"prompt file", "400", "Summarize in at most {{.WordLimit}} words:\n{{.Logs}}", "Summarize in at most 400 words:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newPromptRecorder()
+			defer rec.Close()
+			setPromptRecorderEnv(t, rec)
+			t.Setenv("GEMINI_SUMMARY_WORD_LIMIT", tt.limit)
+			if tt.promptFile != "" {
+				t.Setenv("GEMINI_PROMPT_FILE", writeTestFile(t, "prompt.tmpl", tt.promptFile))
+			}
+
+			if _, err := summarize("42", runLogs{planLog: writeTestFile(t, "plan.log", "Plan: 1 to add, 0 to change, 0 to destroy.\n")}); err != nil {
+				t.Fatalf("summarize: %v", err)
+			}
+			if prompt := rec.Prompt(); !strings.Contains(prompt, tt.want) {
+				t.Errorf("prompt = %q, want it to contain %q", prompt, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryDisabledAndMissingKey(t *testing.T) {
+	tests := []struct {
+		name, enabled, provider string
+		// wantFile is the start of the summary file, "" for none
+		wantFile string
+	}{
+		{"SUMMARY_ENABLED=false", "false", "gemini", ""},
+		{"missing GOOGLE_API_KEY", "", "gemini", "AI summary unavailable. Basic summary:"},
+		{"missing LLM_API_KEY", "", "openai", "AI summary unavailable. Basic summary:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, value := range map[string]string{
+				"TERRAFORM_LOG_DIR": dir, "SUMMARY_ENABLED": tt.enabled, "SUMMARY_PROVIDER": tt.provider,
+				"GOOGLE_API_KEY": "", "LLM_API_KEY": "", "LLM_BASE_URL": "", "GITHUB_STEP_SUMMARY": "",
+			} {
+				t.Setenv(name, value)
+			}
+
+			if _, err := summarize("42", runLogs{planLog: writeTestFile(t, "plan.log", "Plan: 1 to add, 0 to change, 0 to destroy.\n")}); err != nil {
+				t.Fatalf("summarize: %v, want no error", err)
+			}
+			written, err := os.ReadFile(filepath.Join(dir, "terraform-gemini-summary-42.log"))
+			if tt.wantFile == "" {
+				if !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("summary file written (%v), want none", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(written), tt.wantFile) {
+				t.Errorf("summary file = %q, want a basic summary", written)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// defaultSummaryTemplate renders a deterministic summary from the parsed metrics,
+// for SUMMARY_PROVIDER=template where no LLM can be reached.
+const defaultSummaryTemplate = `Terraform run {{.RunID}} {{if .Succeeded}}succeeded{{else}}failed{{end}} ({{.RunType}}).
+{{if .PlanLoaded}}
+Plan: {{.ToAdd}} to add, {{.ToChange}} to change, {{.ToDestroy}} to destroy, {{.ToReplace}} to replace, {{.ToImport}} to import.
+{{- else if .PlanFromLog}}
+Plan: {{.ToAdd}} to add, {{.ToChange}} to change, {{.ToDestroy}} to destroy, {{.ToImport}} to import.
+{{- else}}
+Plan: not available.
+{{- end}}
+{{- if .HasApply}}
+Apply: {{.Added}} added, {{.Changed}} changed, {{.Destroyed}} destroyed, {{.Imported}} imported.
+{{- end}}
+Drift: {{if .DriftDetected}}detected{{if ge .DriftResourceCount 0}} ({{.DriftResourceCount}} resources){{end}}{{else}}none{{end}}.
+{{- if .Warnings}}
+Warnings: {{.Warnings}}.
+{{- end}}
+{{- range $category, $count := .ErrorCategories}}
+Errors ({{$category}}): {{$count}}.
+{{- end}}
+`
+
+// templateSummary renders the summary template, or the text/template in
+// templateFile when set, with the run ID and every Metrics field available.
+func templateSummary(runID string, m Metrics, templateFile string) (string, error) {
+	source := defaultSummaryTemplate
+	if templateFile != "" {
+		raw, err := os.ReadFile(templateFile)
+		if err != nil {
+			return "", fmt.Errorf("reading summary template %s: %w", templateFile, err)
+		}
+		source = string(raw)
+	}
+	tmpl, err := template.New("summary").Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("parsing summary template: %w", err)
+	}
+
+	var builder strings.Builder
+	data := struct {
+		RunID string
+		Metrics
+	}{runID, m}
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("rendering summary template: %w", err)
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,45 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTemplateSummaryCounts(t *testing.T) {
+	clearEnv(t)
+	dir := t.TempDir()
+	for name, value := range map[string]string{
+		"TERRAFORM_LOG_DIR": dir, "SUMMARY_PROVIDER": "template", "SUMMARY_ENABLED": "",
+		"SUMMARY_TEMPLATE_FILE": "", "GITHUB_STEP_SUMMARY": "", "EXTRA_METRICS_FILE": "",
+	} {
+		t.Setenv(name, value)
+	}
+	logs := runLogs{
+		planJSON: writeTestFile(t, "plan.json", replacePlanJSON),
+		applyLog: writeTestFile(t, "apply.log", replaceApplyLog),
+	}
+
+	stats, err := summarize("42", logs)
+	if err != nil {
+		t.Fatalf("summarize: %v", err)
+	}
+	written, err := os.ReadFile(filepath.Join(dir, "terraform-gemini-summary-42.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != stats.Text() {
+		t.Errorf("summary file = %q, want the returned summary %q", written, stats.Text())
+	}
+	for _, want := range []string{
+		"Terraform run 42 succeeded (apply).",
+		"Plan: 1 to add, 1 to change, 0 to destroy, 2 to replace, 0 to import.",
+		"Apply: 4 added, 1 changed, 2 destroyed, 0 imported.",
+		"Drift: none.",
+	} {
+		if !strings.Contains(stats.Text(), want) {
+			t.Errorf("summary = %q, want it to contain %q", stats.Text(), want)
+		}
+	}
+}
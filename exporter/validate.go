@@ -0,0 +1,61 @@
+package exporter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// requiredEnv returns the environment variables that must be set for the given push
+// mode, outputs and dry-run setting. Alternatives are joined with "|".
+func requiredEnv(mode string, outputs []string, dryRun bool) []string {
+	if dryRun {
+		return nil
+	}
+	// A delete must address the group of the run it cleans up, which the hostname
+	// fallback of instanceLabel would not.
+	if mode == "delete" {
+		return []string{"PUSHGATEWAY_JOB", "PUSHGATEWAY_URL|PUSHGATEWAY_ADDRESS", strings.Join(instanceSources, "|")}
+	}
+
+	var required []string
+	for _, output := range outputs {
+		switch output {
+		case "pushgateway":
+			required = append(required, "PUSHGATEWAY_JOB", "PUSHGATEWAY_URL|PUSHGATEWAY_ADDRESS")
+		case "remote_write":
+			required = append(required, "REMOTE_WRITE_URL")
+		}
+	}
+	return required
+}
+
+// validateEnv checks every required variable up front and reports all missing ones
+// in a single error.
+func validateEnv(mode string, outputs []string, dryRun bool) error {
+	if prefix := os.Getenv("METRIC_PREFIX"); prefix != "" && !metricNamePattern.MatchString(prefix+"result") {
+		return fmt.Errorf("%w: METRIC_PREFIX %q does not produce valid metric names", ErrMissingConfig, prefix)
+	}
+
+	if _, err := jobName(); err != nil {
+		return fmt.Errorf("%w: %w", ErrMissingConfig, err)
+	}
+
+	var missing []string
+	for _, req := range requiredEnv(mode, outputs, dryRun) {
+		set := false
+		for _, name := range strings.Split(req, "|") {
+			if os.Getenv(name) != "" {
+				set = true
+				break
+			}
+		}
+		if display := strings.ReplaceAll(req, "|", " or "); !set && !contains(missing, display) {
+			missing = append(missing, display)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing required environment variables: %s", ErrMissingConfig, strings.Join(missing, ", "))
+	}
+	return nil
+}
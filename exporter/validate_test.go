@@ -0,0 +1,113 @@
+package exporter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clearEnv unsets every variable validateEnv may look at, so the host environment
+// does not leak into a test.
+func clearEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{
+		"PUSHGATEWAY_JOB", "PUSHGATEWAY_URL", "PUSHGATEWAY_ADDRESS", "REMOTE_WRITE_URL",
+		"METRIC_PREFIX", "INSTANCE_LABEL", "GITHUB_RUN_ID", "CI_PIPELINE_ID", "BUILD_NUMBER",
+	} {
+		t.Setenv(name, "")
+	}
+}
+
+func TestValidateEnvDeleteInstanceSources(t *testing.T) {
+	for _, source := range instanceSources {
+		t.Run(source, func(t *testing.T) {
+			clearEnv(t)
+			t.Setenv("PUSHGATEWAY_JOB", "terraform")
+			t.Setenv("PUSHGATEWAY_URL", "http://pushgateway:9091")
+			t.Setenv(source, "42")
+			if err := validateEnv("delete", nil, false); err != nil {
+				t.Fatalf("validateEnv: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateEnvDeleteWithoutInstance(t *testing.T) {
+	clearEnv(t)
+	t.Setenv("PUSHGATEWAY_JOB", "terraform")
+	t.Setenv("PUSHGATEWAY_URL", "http://pushgateway:9091")
+
+	err := validateEnv("delete", nil, false)
+	if !errors.Is(err, ErrMissingConfig) {
+		t.Fatalf("err = %v, want ErrMissingConfig", err)
+	}
+	if !strings.Contains(err.Error(), "INSTANCE_LABEL or GITHUB_RUN_ID or CI_PIPELINE_ID or BUILD_NUMBER") {
+		t.Errorf("err = %v, want the instance sources listed", err)
+	}
+}
+
+func TestRequiredEnvByOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs []string
+		dryRun  bool
+		want    []string
+	}{
+		{"pushgateway", []string{"pushgateway"}, false, []string{"PUSHGATEWAY_JOB", "PUSHGATEWAY_URL|PUSHGATEWAY_ADDRESS"}},
+		{"remote_write", []string{"remote_write"}, false, []string{"REMOTE_WRITE_URL"}},
+		{"textfile", []string{"textfile"}, false, nil},
+		{"all", []string{"textfile", "remote_write", "pushgateway"}, false, []string{"REMOTE_WRITE_URL", "PUSHGATEWAY_JOB", "PUSHGATEWAY_URL|PUSHGATEWAY_ADDRESS"}},
+		{"dry run", []string{"pushgateway", "remote_write"}, true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requiredEnv("push", tt.outputs, tt.dryRun); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("requiredEnv(%v) = %v, want %v", tt.outputs, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateEnvByOutput(t *testing.T) {
+	tests := []struct {
+		name    string
+		outputs []string
+		env     map[string]string
+		missing string
+	}{
+		{"pushgateway complete", []string{"pushgateway"}, map[string]string{"PUSHGATEWAY_JOB": "terraform", "PUSHGATEWAY_URL": "pg"}, ""},
+		{"pushgateway by address", []string{"pushgateway"}, map[string]string{"PUSHGATEWAY_JOB": "terraform", "PUSHGATEWAY_ADDRESS": "https://pg"}, ""},
+		{"pushgateway without job", []string{"pushgateway"}, map[string]string{"PUSHGATEWAY_URL": "pg"}, "PUSHGATEWAY_JOB"},
+		{"pushgateway without url", []string{"pushgateway"}, map[string]string{"PUSHGATEWAY_JOB": "terraform"}, "PUSHGATEWAY_URL or PUSHGATEWAY_ADDRESS"},
+		{"remote_write complete", []string{"remote_write"}, map[string]string{"REMOTE_WRITE_URL": "https://prom/api/v1/write"}, ""},
+		{"remote_write without url", []string{"remote_write"}, nil, "REMOTE_WRITE_URL"},
+		{"textfile", []string{"textfile"}, nil, ""},
+		{"all missing", []string{"pushgateway", "remote_write"}, nil, "PUSHGATEWAY_JOB, PUSHGATEWAY_URL or PUSHGATEWAY_ADDRESS, REMOTE_WRITE_URL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearEnv(t)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			err := validateEnv("push", tt.outputs, false)
+			if tt.missing == "" {
+				if err != nil {
+					t.Fatalf("validateEnv: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrMissingConfig) || !strings.HasSuffix(err.Error(), "missing required environment variables: "+tt.missing) {
+				t.Errorf("err = %v, want ErrMissingConfig listing %s", err, tt.missing)
+			}
+		})
+	}
+}
+
+func TestValidateEnvDryRunNeedsNothing(t *testing.T) {
+	clearEnv(t)
+	if err := validateEnv("push", []string{"pushgateway", "remote_write"}, true); err != nil {
+		t.Errorf("validateEnv in a dry run: %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package exporter
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Build information, set at build time with e.g.
+//
+//	go build -ldflags "-X terraform-prometheus-exporter/exporter.Version=v1.2.3 \
+//	  -X terraform-prometheus-exporter/exporter.Commit=$(git rev-parse HEAD) \
+//	  -X terraform-prometheus-exporter/exporter.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	Version   = "dev"
+	Commit    = "dev"
+	BuildDate = "dev"
+)
+
+// buildInfoCollector returns terraform_exporter_build_info, always 1, labelled with
+// the exporter build that produced the metrics.
+func buildInfoCollector() prometheus.Collector {
+	info := prometheus.NewGauge(prometheus.GaugeOpts{
+		Name:        metricName("terraform_exporter_build_info"),
+		Help:        "Always 1; labelled with the exporter version, commit and build date",
+		ConstLabels: prometheus.Labels{"version": Version, "commit": Commit, "build_date": BuildDate},
+	})
+	info.Set(1)
+	return info
+}
@@ -0,0 +1,26 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestBuildInfoCollector(t *testing.T) {
+	for name, value := range map[*string]string{&Version: "v1.2.3", &Commit: "0a1b2c3", &BuildDate: "2024-09-01T10:00:00Z"} {
+		old := *name
+		*name = value
+		t.Cleanup(func() { *name = old })
+	}
+	t.Setenv("METRIC_PREFIX", "")
+
+	got := gatherLabels(t, []prometheus.Collector{buildInfoCollector()}, "terraform_exporter_build_info")
+	want := []map[string]string{{"version": "v1.2.3", "commit": "0a1b2c3", "build_date": "2024-09-01T10:00:00Z"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("terraform_exporter_build_info labels = %v, want %v", got, want)
+	}
+	if values := gatherValues(t, []prometheus.Collector{buildInfoCollector()}); !reflect.DeepEqual(values["terraform_exporter_build_info"], []float64{1}) {
+		t.Errorf("terraform_exporter_build_info = %v, want [1]", values["terraform_exporter_build_info"])
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"os"
+)
+
+// envFlags maps command-line flags to the environment variables they mirror. A flag
+// that is set explicitly takes precedence over the environment variable. Secrets
+// (API keys, passwords, tokens) are environment-only so they never appear in
+// process listings.
+var envFlags = []struct {
+	name, env, usage string
+}{
+	{"plan-path", "TERRAFORM_PLAN_PATH", "path to the plan JSON"},
+	{"plan-log", "TERRAFORM_PLAN_LOG_PATH", "path to the text plan log"},
+	{"apply-log", "TERRAFORM_APPLY_LOG_PATH", "path to the apply log"},
+	{"refresh-log", "TERRAFORM_REFRESH_LOG_PATH", "path to the refresh log"},
+	{"log-dir", "TERRAFORM_LOG_DIR", "directory holding terraform-<phase>-<runID>.log files"},
+	{"start-time", "TERRAFORM_START_TIME", "Unix time the Terraform run started"},
+	{"job", "PUSHGATEWAY_JOB", "Pushgateway job name"},
+	{"pushgateway-url", "PUSHGATEWAY_URL", "Pushgateway host, or a comma-separated list"},
+	{"pushgateway-address", "PUSHGATEWAY_ADDRESS", "full Pushgateway URL, used verbatim when it has a scheme"},
+	{"pushgateway-scheme", "PUSHGATEWAY_SCHEME", "Pushgateway URL scheme (default http)"},
+	{"pushgateway-port", "PUSHGATEWAY_PORT", "Pushgateway port (default 9091)"},
+	{"pushgateway-username", "PUSHGATEWAY_USERNAME", "Pushgateway basic-auth user"},
+	{"push-require-all", "PUSH_REQUIRE_ALL", "fail if any of several Pushgateways fails (true/false)"},
+	{"push-timeout", "PUSH_TIMEOUT_SECONDS", "timeout of each push request in seconds (default 15)"},
+	{"push-retries", "PUSH_RETRIES", "number of push attempts"},
+	{"push-mode", "PUSH_MODE", "push, add or delete"},
+	{"instance", "GITHUB_RUN_ID", "run ID, also the default instance grouping label"},
+	{"instance-label", "INSTANCE_LABEL", "instance grouping label, overriding the CI run ID"},
+	{"workflow", "GITHUB_WORKFLOW", "workflow_name grouping label"},
+	{"commit-message", "COMMIT_MESSAGE", "commit_message grouping label"},
+	{"extra-grouping-labels", "EXTRA_GROUPING_LABELS", "extra grouping labels, e.g. env=prod,region=us-east-1"},
+	{"output", "OUTPUT", "comma-separated outputs: pushgateway, textfile, remote_write"},
+	{"backend", "BACKEND", "alias for OUTPUT when it is unset"},
+	{"duration-histogram", "DURATION_HISTOGRAM", "also export the duration as a histogram (true/false)"},
+	{"duration-buckets", "DURATION_BUCKETS", "comma-separated histogram buckets in seconds"},
+	{"push-state-file", "PUSH_STATE_FILE", "file recording the last pushed run, to skip duplicate pushes"},
+	{"force-push", "FORCE_PUSH", "push even if PUSH_STATE_FILE records this run (true/false)"},
+	{"output-require-all", "OUTPUT_REQUIRE_ALL", "fail if any output fails (true/false)"},
+	{"output-json-path", "OUTPUT_JSON_PATH", "also write all metrics and grouping labels as JSON to this path"},
+	{"textfile-path", "TEXTFILE_PATH", "path for the textfile output"},
+	{"remote-write-url", "REMOTE_WRITE_URL", "remote-write endpoint"},
+	{"remote-write-tenant", "REMOTE_WRITE_TENANT", "X-Scope-OrgID for remote write"},
+	{"remote-write-username", "REMOTE_WRITE_USERNAME", "remote-write basic-auth user"},
+	{"max-label-value-length", "MAX_LABEL_VALUE_LENGTH", "maximum grouping label value length"},
+	{"metric-prefix", "METRIC_PREFIX", "prefix replacing terraform_ in metric names"},
+	{"metric-clamp", "METRIC_CLAMP", "per-metric clamping, e.g. name=min:max"},
+	{"duplicate-metric-policy", "DUPLICATE_METRIC_POLICY", "ignore (default) or sum duplicate metric names"},
+	{"drift-report-path", "DRIFT_REPORT_PATH", "write drifted resource addresses to this file"},
+	{"security-scan-path", "SECURITY_SCAN_PATH", "path to tfsec/Checkov JSON"},
+	{"state-json-path", "TERRAFORM_STATE_JSON_PATH", "path to terraform show -json of the state, for resource inventory"},
+	{"lock-file-path", "TERRAFORM_LOCK_FILE_PATH", "path to .terraform.lock.hcl for provider versions"},
+	{"terraform-version", "TERRAFORM_VERSION", "Terraform version for terraform_version_info when the logs and plan lack it"},
+	{"provider-versions-state-file", "PROVIDER_VERSIONS_STATE_FILE", "file recording provider versions between runs"},
+	{"infracost-json-path", "INFRACOST_JSON_PATH", "path to Infracost JSON output"},
+	{"extra-metrics-file", "EXTRA_METRICS_FILE", "file of name=value custom gauges"},
+	{"metrics-include", "METRICS_INCLUDE", "comma-separated metric names to push, all others are dropped"},
+	{"exporter-timeout-seconds", "EXPORTER_TIMEOUT_SECONDS", "deadline for reading the logs and pushing, in seconds (default 120)"},
+	{"git-branch", "GIT_BRANCH", "branch grouping label (default GITHUB_HEAD_REF, then GITHUB_REF_NAME)"},
+	{"pr-number", "PR_NUMBER", "pull request number grouping label"},
+	{"metrics-exclude", "METRICS_EXCLUDE", "comma-separated metric names not to push"},
+	{"plan-schema-check", "PLAN_SCHEMA_CHECK", "count plan fields not in the documented plan format (true/false)"},
+	{"count-replace-as-add-destroy", "COUNT_REPLACE_AS_ADD_DESTROY", "also count replacements as add and destroy (true/false)"},
+	{"max-action-reasons", "MAX_ACTION_REASONS", "maximum distinct action_reason labels"},
+	{"downtime-resource-types", "DOWNTIME_RESOURCE_TYPES", "comma-separated downtime-inducing resource types"},
+	{"downtime-report-path", "DOWNTIME_REPORT_PATH", "file to write addresses of downtime-inducing replacements to"},
+	{"throttle-patterns", "THROTTLE_PATTERNS", "comma-separated throttling patterns"},
+	{"slow-operation-patterns", "SLOW_OPERATION_PATTERNS", "comma-separated slow-operation patterns"},
+	{"condition-failure-patterns", "CONDITION_FAILURE_PATTERNS", "comma-separated condition failure patterns"},
+	{"fail-on-terraform-error", "FAIL_ON_TERRAFORM_ERROR", "exit 2 when the Terraform run failed (true/false)"},
+	{"summary-enabled", "SUMMARY_ENABLED", "set to false to skip the summary step (default true)"},
+	{"summary-required", "SUMMARY_REQUIRED", "exit 1 when the summary fails (true/false)"},
+	{"run-summary", "RUN_SUMMARY", "set to false to push metrics without summarizing in the same invocation (default true)"},
+	{"summary-provider", "SUMMARY_PROVIDER", "summary provider: gemini, openai or template"},
+	{"summary-template-file", "SUMMARY_TEMPLATE_FILE", "text/template for SUMMARY_PROVIDER=template"},
+	{"summary-language", "SUMMARY_LANGUAGE", "language of the summary"},
+	{"gemini-model", "GEMINI_MODEL", "Gemini model name"},
+	{"prompt-file", "GEMINI_PROMPT_FILE", "prompt template file"},
+	{"summary-timeout", "GEMINI_TIMEOUT_SECONDS", "summary request timeout in seconds (default 60)"},
+	{"gemini-max-retries", "GEMINI_MAX_RETRIES", "retries for rate-limited or 5xx Gemini calls (default 2)"},
+	{"summary-word-limit", "GEMINI_SUMMARY_WORD_LIMIT", "requested maximum summary length in words (default 250)"},
+	{"redact-patterns-file", "REDACT_PATTERNS_FILE", "extra secret patterns to redact before summarizing, one regex per line"},
+	{"llm-base-url", "LLM_BASE_URL", "OpenAI-compatible API base URL"},
+	{"llm-model", "LLM_MODEL", "OpenAI-compatible model name"},
+	{"step-summary", "GITHUB_STEP_SUMMARY", "GitHub step summary file"},
+	{"slack-notify-on", "SLACK_NOTIFY_ON", "when to post to Slack: always, failure or changes"},
+	{"log-max-line-bytes", "LOG_MAX_LINE_BYTES", "longest log line read, in bytes (default 10MB)"},
+	{"log-level", "LOG_LEVEL", "debug, info, warn or error"},
+	{"log-format", "LOG_FORMAT", "text or json"},
+}
+
+// registerEnvFlags defines a string flag for every entry of envFlags.
+func registerEnvFlags(fs *flag.FlagSet) map[string]*string {
+	values := map[string]*string{}
+	for _, f := range envFlags {
+		values[f.name] = fs.String(f.name, "", f.usage+" (env "+f.env+")")
+	}
+	return values
+}
+
+// applyEnvFlags copies explicitly set flags into their environment variables, so
+// the rest of the exporter sees flag values in preference to the environment.
+func applyEnvFlags(fs *flag.FlagSet, values map[string]*string) error {
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, ef := range envFlags {
+			if ef.name == f.Name && err == nil {
+				err = os.Setenv(ef.env, *values[f.Name])
+			}
+		}
+	})
+	return err
+}
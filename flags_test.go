@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"testing"
+)
+
+func parseTestFlags(t *testing.T, args ...string) {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	values := registerEnvFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("parse %v: %v", args, err)
+	}
+	if err := applyEnvFlags(fs, values); err != nil {
+		t.Fatalf("applyEnvFlags: %v", err)
+	}
+}
+
+func TestFlagOverridesEnv(t *testing.T) {
+	unsetFlagEnv(t)
+	t.Setenv("PUSHGATEWAY_JOB", "from-env")
+	t.Setenv("TERRAFORM_PLAN_PATH", "env-plan.json")
+
+	parseTestFlags(t, "--job", "from-flag")
+
+	if got := os.Getenv("PUSHGATEWAY_JOB"); got != "from-flag" {
+		t.Errorf("PUSHGATEWAY_JOB = %q, want the flag value", got)
+	}
+	if got := os.Getenv("TERRAFORM_PLAN_PATH"); got != "env-plan.json" {
+		t.Errorf("TERRAFORM_PLAN_PATH = %q, want the environment value", got)
+	}
+}
+
+func TestUnsetFlagKeepsEnv(t *testing.T) {
+	unsetFlagEnv(t)
+	t.Setenv("PUSH_RETRIES", "5")
+
+	parseTestFlags(t)
+
+	if got := os.Getenv("PUSH_RETRIES"); got != "5" {
+		t.Errorf("PUSH_RETRIES = %q, want the environment value", got)
+	}
+	if _, set := os.LookupEnv("PUSHGATEWAY_JOB"); set {
+		t.Error("an unset flag set PUSHGATEWAY_JOB")
+	}
+}
+
+func TestExplicitEmptyFlagOverridesEnv(t *testing.T) {
+	unsetFlagEnv(t)
+	t.Setenv("METRIC_PREFIX", "infra_")
+
+	parseTestFlags(t, "--metric-prefix=")
+
+	if got := os.Getenv("METRIC_PREFIX"); got != "" {
+		t.Errorf("METRIC_PREFIX = %q, want the explicitly empty flag value", got)
+	}
+}
+
+func TestFlagOverridesConfigFile(t *testing.T) {
+	unsetFlagEnv(t)
+	path := writeConfig(t, "job: from-file\nplan-path: file-plan.json\n")
+
+	if err := loadConfigFile(path); err != nil {
+		t.Fatalf("loadConfigFile: %v", err)
+	}
+	parseTestFlags(t, "--job", "from-flag")
+
+	if got := os.Getenv("PUSHGATEWAY_JOB"); got != "from-flag" {
+		t.Errorf("PUSHGATEWAY_JOB = %q, want the flag value", got)
+	}
+	if got := os.Getenv("TERRAFORM_PLAN_PATH"); got != "file-plan.json" {
+		t.Errorf("TERRAFORM_PLAN_PATH = %q, want the file value", got)
+	}
+}
+
+func TestEnvFlagsAreUnique(t *testing.T) {
+	seen := map[string]bool{}
+	for _, ef := range envFlags {
+		if seen[ef.name] {
+			t.Errorf("flag %q defined twice", ef.name)
+		}
+		seen[ef.name] = true
+	}
+}
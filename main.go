@@ -1,228 +1,112 @@
 package main
 
 import (
-	"bufio"
-	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
 	"os"
-	"strconv"
-	"strings"
-	"time"
 
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/push"
+	"terraform-prometheus-exporter/exporter"
 )
 
-type ResourceChange struct {
-	Type   string `json:"type"`
-	Change struct {
-		Actions []string `json:"actions"`
-	} `json:"change"`
-}
-
-type PlanJSON struct {
-	Timestamp       string           `json:"timestamp"`
-	ResourceChanges []ResourceChange `json:"resource_changes"`
-}
-
-func parseLogStats(path string) (added, changed, destroyed, imported int) {
-	file, err := os.Open(path)
-	if err != nil {
-		return
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.Contains(line, "Apply complete!") {
-			// Terraform summary: Apply complete! Resources: 1 added, 0 changed, 0 destroyed.
-			fields := strings.Split(line, ":")
-			if len(fields) < 2 {
-				continue
-			}
-			stats := strings.Split(fields[1], ",")
-			for _, stat := range stats {
-				parts := strings.Fields(strings.TrimSpace(stat))
-				if len(parts) < 2 {
-					continue
-				}
-				count, _ := strconv.Atoi(parts[0])
-				switch parts[1] {
-				case "added":
-					added = count
-				case "changed":
-					changed = count
-				case "destroyed":
-					destroyed = count
-				case "imported":
-					imported = count
-				}
-			}
-		}
-	}
-	return
-}
+var (
+	dryRun        = flag.Bool("dry-run", false, "print metrics in text exposition format instead of pushing (also DRY_RUN=true)")
+	showVersion   = flag.Bool("version", false, "print the exporter version and exit")
+	configPath    = flag.String("config", "", "YAML or JSON file of settings keyed by flag name")
+	envFlagValues = registerEnvFlags(flag.CommandLine)
+)
 
-func detectDrift(logPath string) float64 {
-	data, err := os.ReadFile(logPath)
-	if err != nil {
-		fmt.Println("Error reading refresh log:", err)
-		return 0
-	}
-	logContent := string(data)
+// Exit codes
+const (
+	exitOK               = 0
+	exitPushError        = 1
+	exitTerraformFailure = 2
+	exitConfigError      = 3
+	exitReadTimeout      = 4
+	exitPlanParseError   = 5
+)
 
-	if strings.Contains(strings.ToLower(logContent), "no changes") {
-		return 0
-	}
-	return 1
+// exitCode decides the process exit code once the exporter finished. A failed
+// Terraform run only fails the exporter when failOnTerraformError is set.
+func exitCode(err error, terraformSucceeded, failOnTerraformError bool) int {
+	switch {
+	case err == nil, errors.Is(err, exporter.ErrAlreadyPushed):
+	case errors.Is(err, exporter.ErrMissingConfig):
+		return exitConfigError
+	case errors.Is(err, exporter.ErrReadTimeout):
+		return exitReadTimeout
+	case errors.Is(err, exporter.ErrPushFailed):
+		return exitPushError
+	case errors.Is(err, exporter.ErrPlanParse):
+		return exitPlanParseError
+	default:
+		return exitPushError
+	}
+	if failOnTerraformError && !terraformSucceeded {
+		return exitTerraformFailure
+	}
+	return exitOK
 }
 
-func collectMetrics() error {
-	planPath := os.Getenv("TERRAFORM_PLAN_PATH")
-	applyLogPath := os.Getenv("TERRAFORM_APPLY_LOG_PATH")
-	refreshLogPath := os.Getenv("TERRAFORM_REFRESH_LOG_PATH")
-	startTimeEnv := os.Getenv("TERRAFORM_START_TIME")
-
-	job := os.Getenv("PUSHGATEWAY_JOB")
-	instance := os.Getenv("GITHUB_RUN_ID")
-	workflowName := os.Getenv("GITHUB_WORKFLOW")
-	commitMsg := os.Getenv("COMMIT_MESSAGE")
-
-	startUnix, _ := strconv.ParseInt(startTimeEnv, 10, 64)
-	execDuration := time.Since(time.Unix(startUnix, 0)).Seconds()
-	timestamp := float64(time.Now().Unix())
-	drift := detectDrift(refreshLogPath)
-
-	// Metrics
-	metrics := map[string]prometheus.Gauge{}
-
-	makeGauge := func(name, help string, value float64) {
-		g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
-		g.Set(value)
-		metrics[name] = g
-	}
-
-	// Plan-only data
-	var plan PlanJSON
-	planFile, err := os.ReadFile(planPath)
-	if err == nil {
-		json.Unmarshal(planFile, &plan)
+func main() {
+	flag.Parse()
+	if *showVersion {
+		fmt.Printf("terraform-prometheus-exporter %s (commit %s, built %s)\n", exporter.Version, exporter.Commit, exporter.BuildDate)
+		return
 	}
-
-	// Tally resource changes
-	total, toAdd, toChange, toDestroy, toImport := 0, 0, 0, 0, 0
-	for _, rc := range plan.ResourceChanges {
-		total++
-		actions := rc.Change.Actions
-		if contains(actions, "create") {
-			toAdd++
-		}
-		if contains(actions, "update") {
-			toChange++
-		}
-		if contains(actions, "delete") {
-			toDestroy++
-		}
-		if contains(actions, "import") {
-			toImport++
-		}
+	if *dryRun {
+		os.Setenv("DRY_RUN", "true")
 	}
-
-	if plan.Timestamp != "" {
-		parsedTime, err := time.Parse(time.RFC3339, plan.Timestamp)
-		if err == nil {
-			timestamp = float64(parsedTime.Unix())
+	if *configPath != "" {
+		if err := loadConfigFile(*configPath); err != nil {
+			slog.Error("loading config failed", "error", err)
+			os.Exit(exitConfigError)
 		}
 	}
-
-	// Export common metrics
-	makeGauge("terraform_execution_duration_seconds", "Time taken for execution", execDuration)
-	makeGauge("terraform_timestamp", "Unix timestamp of run", timestamp)
-	makeGauge("terraform_drift_detected", "Drift found during refresh", float64(drift))
-	makeGauge("terraform_resources_total", "Total planned resource changes", float64(total))
-	makeGauge("terraform_to_add", "Resources planned to be added", float64(toAdd))
-	makeGauge("terraform_to_change", "Resources planned to be changed", float64(toChange))
-	makeGauge("terraform_to_destroy", "Resources planned to be destroyed", float64(toDestroy))
-	makeGauge("terraform_to_import", "Resources planned to be imported", float64(toImport))
-
-	if applyLogPath != "" {
-		// Apply context
-		added, changed, destroyed, imported := parseLogStats(applyLogPath)
-		makeGauge("terraform_added", "Resources actually added", float64(added))
-		makeGauge("terraform_changed", "Resources actually changed", float64(changed))
-		makeGauge("terraform_destroyed", "Resources actually destroyed", float64(destroyed))
-		makeGauge("terraform_imported", "Resources actually imported", float64(imported))
-	}
-
-	resultLogPath := planPath
-	if applyLogPath != "" {
-		resultLogPath = applyLogPath
-	}
-	if isTerraformRunSuccessful(resultLogPath) {
-		makeGauge("terraform_result", "1=success, 0=failure", 1)
-	} else {
-		makeGauge("terraform_result", "1=success, 0=failure", 0)
-	}
-
-	// Push
-	pushURL := "http://" + os.Getenv("PUSHGATEWAY_URL") + ":9091"
-	pusher := push.New(pushURL, job).
-		Grouping("instance", instance).
-		Grouping("commit_message", commitMsg).
-		Grouping("workflow_name", workflowName).
-		Grouping("job", job)
-
-	for _, g := range metrics {
-		pusher.Collector(g)
+	if err := applyEnvFlags(flag.CommandLine, envFlagValues); err != nil {
+		slog.Error("applying flags failed", "error", err)
+		os.Exit(1)
 	}
-	return pusher.Push()
-}
+	setupLogging()
+	failOnTerraformError := os.Getenv("FAIL_ON_TERRAFORM_ERROR") == "true"
 
-func contains(slice []string, val string) bool {
-	for _, v := range slice {
-		if v == val {
-			return true
+	if flag.NArg() > 1 && flag.Arg(0) == "run" {
+		succeeded, err := exporter.RunAll(flag.Arg(1))
+		if errors.Is(err, exporter.ErrPlanParse) && !errors.Is(err, exporter.ErrPushFailed) {
+			slog.Error("parsing the plan failed", "error", err)
+		} else if err != nil && !errors.Is(err, exporter.ErrAlreadyPushed) {
+			slog.Error("pushing metrics failed", "error", err)
 		}
+		os.Exit(exitCode(err, succeeded, failOnTerraformError))
 	}
-	return false
-}
-
-func isTerraformRunSuccessful(logPath string) bool {
-	file, err := os.Open(logPath)
-	if err != nil {
-		return false
-	}
-	defer file.Close()
 
-	hasError := false
-	hasNoChanges := false
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.ToLower(scanner.Text())
-
-		if strings.Contains(line, "error") {
-			hasError = true
+	switch mode := os.Getenv("PUSH_MODE"); mode {
+	case "", "push", "add":
+	case "delete":
+		if err := exporter.DeleteMetrics(); err != nil {
+			slog.Error("deleting metrics failed", "error", err)
+			os.Exit(exitCode(err, true, false))
 		}
-		if strings.Contains(line, "no changes") {
-			hasNoChanges = true
-		}
-	}
-
-	if hasError && !hasNoChanges {
-		return false
-	}
-	return true
-}
-
-func main() {
-	if err := collectMetrics(); err != nil {
-		fmt.Println("Error pushing metrics:", err)
-		os.Exit(1)
-	}
-	if err := QueryGemini(os.Getenv("GITHUB_RUN_ID")); err != nil {
-		fmt.Println("Error Getting Gemini Files:", err)
-		os.Exit(1)
+		slog.Info("deleted metrics", "job", os.Getenv("PUSHGATEWAY_JOB"))
+		return
+	default:
+		slog.Error("unknown PUSH_MODE", "mode", mode)
+		os.Exit(exitConfigError)
+	}
+
+	m, err := exporter.PushAndSummarize(exporter.ConfigFromEnv(), os.Getenv("GITHUB_RUN_ID"))
+	code := exitCode(err, m.Succeeded, failOnTerraformError)
+	switch {
+	case errors.Is(err, exporter.ErrAlreadyPushed):
+	case code == exitPlanParseError:
+		slog.Error("parsing the plan failed", "error", err)
+	case err != nil:
+		slog.Error("exporter failed", "error", err)
+	case code == exitTerraformFailure:
+		slog.Error("Terraform run failed", "exit_code", code)
+	}
+	if code != exitOK {
+		os.Exit(code)
 	}
 }
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"terraform-prometheus-exporter/exporter"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name                 string
+		err                  error
+		succeeded, failOnErr bool
+		want                 int
+	}{
+		{"ok", nil, true, true, exitOK},
+		{"terraform failed, not checked", nil, false, false, exitOK},
+		{"terraform failed", nil, false, true, exitTerraformFailure},
+		{"already pushed", exporter.ErrAlreadyPushed, true, true, exitOK},
+		{"already pushed, terraform failed", exporter.ErrAlreadyPushed, false, true, exitTerraformFailure},
+		{"config", fmt.Errorf("%w: no job", exporter.ErrMissingConfig), true, false, exitConfigError},
+		{"read timeout", fmt.Errorf("%w: deadline", exporter.ErrReadTimeout), true, false, exitReadTimeout},
+		{"push", fmt.Errorf("%w: 503", exporter.ErrPushFailed), true, false, exitPushError},
+		{"plan parse", fmt.Errorf("%w: plan.json", exporter.ErrPlanParse), true, false, exitPlanParseError},
+		{"plan parse, terraform failed", fmt.Errorf("%w: plan.json", exporter.ErrPlanParse), false, true, exitPlanParseError},
+		{"plan parse and push", errors.Join(fmt.Errorf("%w: 503", exporter.ErrPushFailed), exporter.ErrPlanParse), true, false, exitPushError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err, tt.succeeded, tt.failOnErr); got != tt.want {
+				t.Errorf("exitCode = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
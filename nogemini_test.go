@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestNogeminiBuild checks that the nogemini tag still builds and really leaves out
+// the genai dependency.
+func TestNogeminiBuild(t *testing.T) {
+	if testing.Short() {
+		t.Skip("runs the go tool")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go tool not found")
+	}
+	if out, err := exec.Command(goBin, "vet", "-tags", "nogemini", "./...").CombinedOutput(); err != nil {
+		t.Fatalf("go vet -tags nogemini: %v\n%s", err, out)
+	}
+	out, err := exec.Command(goBin, "list", "-deps", "-tags", "nogemini", ".").Output()
+	if err != nil {
+		t.Fatalf("go list -tags nogemini: %v", err)
+	}
+	if strings.Contains(string(out), "google.golang.org/genai") {
+		t.Errorf("nogemini build still depends on google.golang.org/genai")
+	}
+}